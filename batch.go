@@ -0,0 +1,291 @@
+package gobatis
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+var (
+	ErrorBatchArgsNeedBeSlice = errors.New(`gobatis: ExecuteBatch args need be slice or array`)
+	ErrorBatchNeedWriteMapper = errors.New(`gobatis: ExecuteBatch need insert, update or delete mapper`)
+)
+
+// BatchOptions options of ExecuteBatch
+type BatchOptions struct {
+	// Collection the variable name of the foreach collection, elements of argsSlice are split into chunks
+	// and bound to it, each chunk is executed as one statement.
+	// if empty, each element of argsSlice is the args of one execution, the prepared statement is reused
+	// inside transaction.
+	Collection string
+
+	// Args the other variables shared by all the chunks, only used with Collection
+	Args Args
+
+	// ChunkSize max elements of each chunk, only used with Collection,
+	// 0 means as many as the placeholder limit of the database allows.
+	ChunkSize int
+}
+
+// ChunkError error of the chunk or the element [Start, End) of argsSlice
+type ChunkError struct {
+	Start int
+	End   int
+	Err   error
+}
+
+func (e *ChunkError) Error() string {
+	return fmt.Sprintf(`chunk [%d, %d): %v`, e.Start, e.End, e.Err)
+}
+
+func (e *ChunkError) Unwrap() error {
+	return e.Err
+}
+
+// BatchError errors of the failed chunks of ExecuteBatch, unwrap to the first one
+type BatchError struct {
+	Errors []*ChunkError
+}
+
+func (e *BatchError) Error() string {
+	messages := make([]string, 0, len(e.Errors))
+	for _, err := range e.Errors {
+		messages = append(messages, err.Error())
+	}
+	return fmt.Sprintf(`gobatis: batch: %d chunks failed: %s`, len(e.Errors), strings.Join(messages, `; `))
+}
+
+func (e *BatchError) Unwrap() error {
+	return e.Errors[0]
+}
+
+// ExecuteBatch execute the insert, update or delete mapper with each element of argsSlice,
+// RowsAffected is the sum of all the executions, Error is *BatchError if any chunk failed.
+//
+// without opts.Collection, all the elements are executed in one transaction (the current one if in transaction),
+// the prepared statement is reused, and the transaction is rolled back when any element failed.
+//
+// with opts.Collection, the elements are split into chunks by opts.ChunkSize or the placeholder limit
+// of the database (postgres 65535, sqlserver 2100), the failed chunks don't stop the others unless in transaction.
+//
+// forexample:
+//
+//	db.Mapper(`insertUsers`).ExecuteBatch(users, &gobatis.BatchOptions{Collection: `list`})
+func (b *DB) ExecuteBatch(argsSlice any, opts *BatchOptions) *DB {
+	if b.Error != nil {
+		return b
+	}
+
+	db := b.Clone()
+	if db.mapper == nil {
+		db.Error = db.wrapError(PhaseBind, ErrorMapperCallFirst)
+		return db
+	}
+	switch db.mapperType {
+	case mapperInsert, mapperUpdate, mapperDelete:
+	default:
+		db.Error = db.wrapError(PhaseBind, ErrorBatchNeedWriteMapper)
+		return db
+	}
+
+	elems := reflect.ValueOf(argsSlice)
+	for elems.Kind() == reflect.Ptr {
+		elems = elems.Elem()
+	}
+	switch elems.Kind() {
+	case reflect.Slice, reflect.Array:
+	default:
+		db.Error = db.wrapError(PhaseBind, ErrorBatchArgsNeedBeSlice)
+		return db
+	}
+
+	if opts == nil {
+		opts = &BatchOptions{}
+	}
+	db.RowsAffected = 0
+	if opts.Collection != `` {
+		return db.executeChunks(elems, opts)
+	}
+	return db.executeEach(elems)
+}
+
+// executeEach execute each element in transaction with the prepared statements reused
+func (db *DB) executeEach(elems reflect.Value) *DB {
+	var rowsAffected int64
+	run := func(tx *DB) error {
+		stmts := make(map[string]*sql.Stmt, 1)
+		defer func() {
+			for _, stmt := range stmts {
+				_ = stmt.Close()
+			}
+		}()
+
+		for i := 0; i < elems.Len(); i++ {
+			bindVars, err := tx.render(elems.Index(i).Interface())
+			if err != nil {
+				tx.bindVars = nil
+				return &BatchError{Errors: []*ChunkError{{Start: i, End: i + 1, Err: tx.wrapError(PhaseBind, err)}}}
+			}
+			tx.bindVars = bindVars
+			if err = tx.executeElement(stmts, bindVars, &rowsAffected); err != nil {
+				return &BatchError{Errors: []*ChunkError{{Start: i, End: i + 1, Err: err}}}
+			}
+		}
+		tx.invalidateCache()
+		return nil
+	}
+
+	if db.tx != nil {
+		db.Error = run(db)
+	} else {
+		db.Error = db.Transaction(run)
+	}
+	if db.Error == nil {
+		db.RowsAffected = rowsAffected
+	}
+	return db
+}
+
+// executeElement execute one element with the prepared statement of its sql within the statement timeout,
+// each element is traced, observed and checked for slow query as a statement.
+func (tx *DB) executeElement(stmts map[string]*sql.Stmt, bindVars *BindVar, rowsAffected *int64) (err error) {
+	el := tx.Clone()
+	el.bindVars, el.startTime = bindVars, time.Now()
+	var rows int64
+	end := el.startSpan(el.statementType())
+	defer func() {
+		el.Error = err
+		el.done(end, rows)
+	}()
+
+	if err = el.withDeadline(); err != nil {
+		return el.wrapError(PhaseBind, err)
+	}
+	defer el.releaseDeadline()
+
+	// the dynamic statements may render different sql
+	stmt, ok := stmts[bindVars.stateSql]
+	if !ok {
+		if stmt, err = el.tx.PrepareContext(el.ctx, bindVars.stateSql); err != nil {
+			return el.wrapError(PhaseExec, err)
+		}
+		stmts[bindVars.stateSql] = stmt
+	}
+
+	inv := el.invocation(bindVars)
+	err = el.intercept(PhaseExec, inv, func() error {
+		result, err := stmt.ExecContext(el.ctx, bindVars.args...)
+		if err != nil {
+			return err
+		}
+		inv.RowsAffected, err = result.RowsAffected()
+		return err
+	})
+	if err != nil {
+		el.log(LogLevelError, err, bindVars.stateSql, maskArgs(bindVars.args, bindVars.masks)...)
+		return el.wrapError(PhaseExec, err)
+	}
+	rows = inv.RowsAffected
+	*rowsAffected += rows
+	el.written()
+	el.log(LogLevelDebug, nil, bindVars.stateSql, maskArgs(bindVars.args, bindVars.masks)...)
+	return nil
+}
+
+// executeChunks split the elements into chunks bound to the foreach collection
+func (db *DB) executeChunks(elems reflect.Value, opts *BatchOptions) *DB {
+	var errs []*ChunkError
+
+	// the array passed by value can't be sliced
+	if elems.Kind() == reflect.Array && !elems.CanAddr() {
+		addressable := reflect.New(elems.Type()).Elem()
+		addressable.Set(elems)
+		elems = addressable
+	}
+
+	render := func(start, end int) (*BindVar, error) {
+		variables := make(Args, len(opts.Args)+1)
+		for k, v := range opts.Args {
+			variables[k] = v
+		}
+		variables[opts.Collection] = elems.Slice(start, end).Interface()
+		return db.render(variables)
+	}
+
+	// execute the chunk, split it into halves if the placeholders exceed the limit
+	var execute func(start, end int) bool
+	execute = func(start, end int) bool {
+		bindVars, err := render(start, end)
+		if err != nil {
+			db.bindVars = nil
+			errs = append(errs, &ChunkError{Start: start, End: end, Err: db.wrapError(PhaseBind, err)})
+			return db.tx == nil
+		}
+		if len(bindVars.args) > dialectOf(bindVars.typ).MaxParams() && end-start > 1 {
+			middle := start + (end-start)/2
+			return execute(start, middle) && execute(middle, end)
+		}
+
+		chunk := db.Clone()
+		chunk.bindVars = bindVars
+		spanEnd := chunk.startSpan(chunk.statementType())
+		chunk.exec(bindVars)
+		chunk.done(spanEnd, chunk.RowsAffected)
+		if chunk.Error != nil {
+			chunk.log(LogLevelError, chunk.Error, bindVars.stateSql, maskArgs(bindVars.args, bindVars.masks)...)
+			errs = append(errs, &ChunkError{Start: start, End: end, Err: chunk.Error})
+			// the transaction is aborted by the failed statement
+			return db.tx == nil
+		}
+		chunk.log(LogLevelDebug, nil, bindVars.stateSql, maskArgs(bindVars.args, bindVars.masks)...)
+		db.RowsAffected += chunk.RowsAffected
+		return true
+	}
+
+	if elems.Len() == 0 {
+		return db
+	}
+
+	size := opts.ChunkSize
+	if size <= 0 && elems.Len() > 1 {
+		// estimate by the placeholders of one and two elements, the fixed ones are subtracted from the limit
+		one, err := render(0, 1)
+		if err != nil {
+			db.Error = db.wrapError(PhaseBind, err)
+			return db
+		}
+		two, err := render(0, 2)
+		if err != nil {
+			db.Error = db.wrapError(PhaseBind, err)
+			return db
+		}
+		size = elems.Len()
+		if each := len(two.args) - len(one.args); each > 0 {
+			fixed := len(one.args) - each
+			size = (dialectOf(one.typ).MaxParams() - fixed) / each
+		}
+		if size < 1 {
+			size = 1
+		}
+	} else if size <= 0 {
+		size = 1
+	}
+
+	for start := 0; start < elems.Len(); start += size {
+		end := start + size
+		if end > elems.Len() {
+			end = elems.Len()
+		}
+		if !execute(start, end) {
+			break
+		}
+	}
+
+	if errs != nil {
+		db.Error = &BatchError{Errors: errs}
+	}
+	return db
+}
@@ -0,0 +1,213 @@
+package gobatis
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const batchMapper = `<mapper>
+<insert id="insertUsers">insert into users (name, tag) values <foreach collection="list" item="item" separator=",">(#{item.Name}, #{tag})</foreach></insert>
+<insert id="insertUser">insert into users (name) values (#{Name})</insert>
+<select id="find">select * from users</select>
+</mapper>`
+
+type batchUser struct {
+	Name string
+}
+
+func batchUsers(n int) []batchUser {
+	users := make([]batchUser, n)
+	for i := range users {
+		users[i].Name = strings.Repeat(`a`, i+1)
+	}
+	return users
+}
+
+func TestExecuteBatchChunks(t *testing.T) {
+	db, server := openTest(t, batchMapper)
+
+	ndb := db.Mapper(`insertUsers`).ExecuteBatch(batchUsers(5), &BatchOptions{Collection: `list`, Args: Args{`tag`: `x`}, ChunkSize: 2})
+	if ndb.Error != nil {
+		t.Fatalf(`ExecuteBatch() error = %v`, ndb.Error)
+	}
+	if ndb.RowsAffected != 3 {
+		t.Errorf(`RowsAffected = %d, want 3`, ndb.RowsAffected)
+	}
+	want := []string{
+		`insert into users (name, tag) values (?, ?),(?, ?)`,
+		`insert into users (name, tag) values (?, ?),(?, ?)`,
+		`insert into users (name, tag) values (?, ?)`,
+	}
+	if got := server.log(); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("executed\n got: %q\nwant: %q", got, want)
+	}
+}
+
+func TestExecuteBatchChunkError(t *testing.T) {
+	db, server := openTest(t, batchMapper)
+	cause := errors.New(`boom`)
+	server.setFail(func(statement string) error {
+		// the last chunk of one element fails
+		if statement == `insert into users (name, tag) values (?, ?)` {
+			return cause
+		}
+		return nil
+	})
+
+	ndb := db.Mapper(`insertUsers`).ExecuteBatch(batchUsers(5), &BatchOptions{Collection: `list`, Args: Args{`tag`: `x`}, ChunkSize: 2})
+	var batchErr *BatchError
+	if !errors.As(ndb.Error, &batchErr) || len(batchErr.Errors) != 1 {
+		t.Fatalf(`ExecuteBatch() error = %v, want *BatchError`, ndb.Error)
+	}
+	if e := batchErr.Errors[0]; e.Start != 4 || e.End != 5 || !errors.Is(e, cause) {
+		t.Errorf(`ChunkError = %v, want chunk [4, 5) of %v`, e, cause)
+	}
+	if !errors.Is(ndb.Error, cause) {
+		t.Errorf(`errors.Is(%v, %v) = false`, ndb.Error, cause)
+	}
+	// the other chunks are executed
+	if n := server.count(`insert into users (name, tag) values (?, ?),(?, ?)`); n != 2 {
+		t.Errorf(`%d chunks executed, want 2`, n)
+	}
+}
+
+func TestExecuteBatchPlaceholderLimit(t *testing.T) {
+	db, server := openTest(t, strings.Replace(batchMapper, `<mapper>`, `<mapper type="sqlserver">`, 1))
+
+	// 2 placeholders of each element, 2100 placeholders at most of sqlserver
+	ndb := db.Mapper(`insertUsers`).ExecuteBatch(batchUsers(1500), &BatchOptions{Collection: `list`, Args: Args{`tag`: `x`}})
+	if ndb.Error != nil {
+		t.Fatalf(`ExecuteBatch() error = %v`, ndb.Error)
+	}
+	statements := server.log()
+	if len(statements) != 2 {
+		t.Fatalf(`%d statements executed, want 2`, len(statements))
+	}
+	for _, statement := range statements {
+		if n := strings.Count(statement, `@p`); n > 2100 {
+			t.Errorf(`%d placeholders in one statement`, n)
+		}
+	}
+}
+
+func TestExecuteBatchFixedArgs(t *testing.T) {
+	db, server := openTest(t, `<mapper type="sqlserver">
+<insert id="insertUsers">insert into users (name) select name from (values <foreach collection="list" item="item" separator=",">(#{item.Name})</foreach>) v (name)
+where name not in (<foreach collection="reserved" item="name" separator=",">#{name}</foreach>)</insert>
+</mapper>`)
+
+	// 1 placeholder of each element and 100 fixed ones, 2000 elements fit in one statement
+	reserved := make([]string, 100)
+	ndb := db.Mapper(`insertUsers`).ExecuteBatch(batchUsers(2000), &BatchOptions{Collection: `list`, Args: Args{`reserved`: reserved}})
+	if ndb.Error != nil {
+		t.Fatalf(`ExecuteBatch() error = %v`, ndb.Error)
+	}
+	if statements := server.log(); len(statements) != 1 {
+		t.Errorf(`%d statements executed, want 1`, len(statements))
+	}
+}
+
+func TestExecuteBatchArray(t *testing.T) {
+	db, server := openTest(t, batchMapper)
+
+	// the array passed by value is not addressable
+	users := [3]batchUser{{Name: `a`}, {Name: `b`}, {Name: `c`}}
+	ndb := db.Mapper(`insertUsers`).ExecuteBatch(users, &BatchOptions{Collection: `list`, Args: Args{`tag`: `x`}, ChunkSize: 2})
+	if ndb.Error != nil {
+		t.Fatalf(`ExecuteBatch() error = %v`, ndb.Error)
+	}
+	if statements := server.log(); len(statements) != 2 {
+		t.Errorf(`%d statements executed, want 2`, len(statements))
+	}
+}
+
+func TestExecuteBatchEach(t *testing.T) {
+	db, server := openTest(t, batchMapper)
+
+	ndb := db.Mapper(`insertUser`).ExecuteBatch(batchUsers(3), nil)
+	if ndb.Error != nil {
+		t.Fatalf(`ExecuteBatch() error = %v`, ndb.Error)
+	}
+	if ndb.RowsAffected != 3 {
+		t.Errorf(`RowsAffected = %d, want 3`, ndb.RowsAffected)
+	}
+	statement := `insert into users (name) values (?)`
+	want := []string{`BEGIN`, statement, statement, statement, `COMMIT`}
+	if got := server.log(); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("executed\n got: %q\nwant: %q", got, want)
+	}
+}
+
+func TestExecuteBatchEachError(t *testing.T) {
+	db, server := openTest(t, batchMapper)
+	cause, n := errors.New(`boom`), 0
+	server.setFail(func(statement string) error {
+		// the second element fails
+		if statement == `insert into users (name) values (?)` {
+			if n++; n == 2 {
+				return cause
+			}
+		}
+		return nil
+	})
+
+	ndb := db.Mapper(`insertUser`).ExecuteBatch(batchUsers(3), nil)
+	var chunkErr *ChunkError
+	if !errors.As(ndb.Error, &chunkErr) || chunkErr.Start != 1 || chunkErr.End != 2 || !errors.Is(ndb.Error, cause) {
+		t.Fatalf(`ExecuteBatch() error = %v, want chunk [1, 2) of %v`, ndb.Error, cause)
+	}
+	if ndb.RowsAffected != 0 {
+		t.Errorf(`RowsAffected = %d, want 0`, ndb.RowsAffected)
+	}
+	if got := server.log(); got[len(got)-1] != `ROLLBACK` || server.count(`insert into users (name) values (?)`) != 2 {
+		t.Errorf(`executed %q, want rolled back after the second element`, got)
+	}
+}
+
+func TestExecuteBatchErrors(t *testing.T) {
+	db, _ := openTest(t, batchMapper)
+
+	if err := db.Mapper(`find`).ExecuteBatch(batchUsers(1), nil).Error; !errors.Is(err, ErrorBatchNeedWriteMapper) {
+		t.Errorf(`ExecuteBatch() with select error = %v`, err)
+	}
+	if err := db.Mapper(`insertUser`).ExecuteBatch(batchUser{}, nil).Error; !errors.Is(err, ErrorBatchArgsNeedBeSlice) {
+		t.Errorf(`ExecuteBatch() with struct error = %v`, err)
+	}
+	err := db.ExecuteBatch(batchUsers(1), nil).Error
+	var e *Error
+	if !errors.Is(err, ErrorMapperCallFirst) || !errors.As(err, &e) || e.Phase != PhaseBind {
+		t.Errorf(`ExecuteBatch() without mapper error = %v`, err)
+	}
+}
+
+func TestExecuteBatchObserved(t *testing.T) {
+	tracer := &testTracer{}
+	db, _ := openTest(t, batchMapper, WithTracer(tracer), WithStats())
+
+	// each element and each chunk is traced and counted as a statement
+	if err := db.Mapper(`insertUser`).ExecuteBatch(batchUsers(2), nil).Error; err != nil {
+		t.Fatalf(`ExecuteBatch() error = %v`, err)
+	}
+	if err := db.Mapper(`insertUsers`).ExecuteBatch(batchUsers(3), &BatchOptions{Collection: `list`, ChunkSize: 2}).Error; err != nil {
+		t.Fatalf(`ExecuteBatch() error = %v`, err)
+	}
+	want := []string{
+		`insert:insertUser<transaction:insertUser> gobatistest "insert into users (name) values (?)" rows=1 err=<nil>`,
+		`insert:insertUser<transaction:insertUser> gobatistest "insert into users (name) values (?)" rows=1 err=<nil>`,
+		`transaction:insertUser<> gobatistest "" rows=0 err=<nil>`,
+		`insert:insertUsers<> gobatistest "insert into users (name, tag) values (?, ?),(?, ?)" rows=1 err=<nil>`,
+		`insert:insertUsers<> gobatistest "insert into users (name, tag) values (?, ?)" rows=1 err=<nil>`,
+	}
+	if !reflect.DeepEqual(tracer.spans, want) {
+		t.Errorf("spans\n got: %q\nwant: %q", tracer.spans, want)
+	}
+	stats := db.Stats()
+	if each := stats[`insertUser`]; each.Calls != 2 || each.Rows != 2 {
+		t.Errorf(`Stats()[insertUser] = %+v`, each)
+	}
+	if chunks := stats[`insertUsers`]; chunks.Calls != 2 || chunks.Rows != 2 {
+		t.Errorf(`Stats()[insertUsers] = %+v`, chunks)
+	}
+}
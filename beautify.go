@@ -0,0 +1,151 @@
+package gobatis
+
+import (
+	"strings"
+)
+
+// isSpace report whether c is a whitespace of sql
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f' || c == '\v'
+}
+
+// dollarTag return the tag of postgres dollar-quoted string at the beginning of s, such as $$ or $body$
+// return empty string if s is not beginning with a dollar tag, eg: placeholder $1.
+func dollarTag(s string) string {
+	if len(s) < 2 || s[0] != '$' {
+		return ``
+	}
+	for i := 1; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '$':
+			return s[:i+1]
+		case c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= 0x80:
+		case c >= '0' && c <= '9' && i > 1:
+		default:
+			return ``
+		}
+	}
+	return ``
+}
+
+// quotedEnd return the index after the closing quote of the quoted string beginning at s[0]
+// the quote is escaped by doubling it, and by backslash if backslash is true.
+func quotedEnd(s string, backslash bool) int {
+	quote := s[0]
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			if backslash {
+				i++
+			}
+		case quote:
+			if i+1 < len(s) && s[i+1] == quote {
+				i++
+				continue
+			}
+			return i + 1
+		}
+	}
+	return len(s)
+}
+
+// literalEnd return the index after the quoted literal or identifier, dollar-quoted string or comment
+// beginning at statement[i], -1 if statement[i] doesn't begin any of them.
+// the quotes are escaped by backslash in mysql style, and in postgres escape strings E'...'.
+// the dollar-quoted strings are postgres only.
+func literalEnd(statement string, i int, style int) int {
+	c := statement[i]
+	switch {
+	case c == '\'' || c == '"' || c == '`':
+		backslash := style == literalMysql && c != '`' || style == literalPostgres && c == '\'' && escapeString(statement, i)
+		return i + quotedEnd(statement[i:], backslash)
+	case c == '$' && style == literalPostgres:
+		tag := dollarTag(statement[i:])
+		if tag == `` {
+			return -1
+		}
+		end := strings.Index(statement[i+len(tag):], tag)
+		if end < 0 {
+			return len(statement)
+		}
+		return i + len(tag) + end + len(tag)
+	case c == '-' && strings.HasPrefix(statement[i:], `--`):
+		end := strings.IndexByte(statement[i:], '\n')
+		if end < 0 {
+			return len(statement)
+		}
+		return i + end
+	case c == '/' && strings.HasPrefix(statement[i:], `/*`):
+		end := strings.Index(statement[i+2:], `*/`)
+		if end < 0 {
+			return len(statement)
+		}
+		return i + 2 + end + 2
+	}
+	return -1
+}
+
+// escapeString report whether the quote at statement[i] begins a postgres escape string E'...',
+// the E must not be the end of an identifier, eg: the quote of name'...'.
+func escapeString(statement string, i int) bool {
+	if i < 1 || statement[i-1] != 'E' && statement[i-1] != 'e' {
+		return false
+	}
+	return i < 2 || !isIdentifierChar(statement[i-2])
+}
+
+// isIdentifierChar report whether c is a part of the unquoted identifiers
+func isIdentifierChar(c byte) bool {
+	return c == '_' || c == '$' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c >= 0x80
+}
+
+// beautifySQL collapse the whitespaces of the sql statement into single space
+// the quoted literals and identifiers, dollar-quoted strings and comments are kept as they are,
+// and the line comments keep their trailing newline, so the rest of the statement isn't commented out.
+func beautifySQL(typ string, statement string) string {
+	var builder strings.Builder
+	builder.Grow(len(statement))
+
+	// backslash escapes and dollar-quoted strings depend on the database
+	style := literalStyleOf(dialectOf(typ))
+
+	var space, newline bool
+	flush := func() {
+		switch {
+		case newline:
+			builder.WriteByte('\n')
+		case space && builder.Len() > 0:
+			builder.WriteByte(' ')
+		}
+		space, newline = false, false
+	}
+
+	for i := 0; i < len(statement); {
+		c := statement[i]
+		switch {
+		case isSpace(c):
+			space = true
+			i++
+			continue
+		case c == '-' && strings.HasPrefix(statement[i:], `--`):
+			end := literalEnd(statement, i, style)
+			flush()
+			builder.WriteString(strings.TrimRight(statement[i:end], " \t\r"))
+			i = end
+			newline = true
+		default:
+			if end := literalEnd(statement, i, style); end > 0 {
+				flush()
+				builder.WriteString(statement[i:end])
+				i = end
+				continue
+			}
+			flush()
+			builder.WriteByte(c)
+			i++
+		}
+	}
+
+	return builder.String()
+}
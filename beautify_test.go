@@ -0,0 +1,54 @@
+package gobatis
+
+import "testing"
+
+func TestBeautifySQL(t *testing.T) {
+	tests := []struct {
+		name      string
+		typ       string
+		statement string
+		want      string
+	}{
+		{`collapse spaces`, `postgres`, "\n\tselect  *\n\tfrom users\r\n\twhere id = $1  ", `select * from users where id = $1`},
+		{`quoted`, `postgres`, "select 'a  b', \"c\n d\"  from t", "select 'a  b', \"c\n d\" from t"},
+		{`doubled quote`, `postgres`, "select 'it''s  ok'   from t", "select 'it''s  ok' from t"},
+		{`escape string`, `postgres`, "select E'a\\'  b'   from t", "select E'a\\'  b' from t"},
+		{`dollar quoted`, `postgres`, "select $body$ a   b $body$,   $$ c  d $$", "select $body$ a   b $body$, $$ c  d $$"},
+		{`placeholder not dollar tag`, `postgres`, "select $1,   $2", "select $1, $2"},
+		{`line comment`, `postgres`, "select 1 -- note  \n   from t", "select 1 -- note\nfrom t"},
+		{`line comment at end`, `postgres`, "select 1 -- note", "select 1 -- note"},
+		{`block comment`, `postgres`, "select /* a   b */   1", "select /* a   b */ 1"},
+		{`mysql backslash`, `mysql`, "select 'a\\'  b',   `c  d`", "select 'a\\'  b', `c  d`"},
+		{`standard backslash`, `sqlite3`, "select 'a\\'   ,  'b'", "select 'a\\' , 'b'"},
+		{`escape string at start`, `postgres`, "E'a\\'  b'   x", "E'a\\'  b' x"},
+		{`identifier ending with e`, `postgres`, "select date'a\\'   ,  'b'", "select date'a\\' , 'b'"},
+		{`escape string not postgres`, `sqlite3`, "select E'a\\'   ,  'b'", "select E'a\\' , 'b'"},
+		{`dollar not postgres`, `mysql`, "select a$$   b$$", "select a$$ b$$"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := beautifySQL(tt.typ, tt.statement); got != tt.want {
+				t.Errorf("beautifySQL()\n got: %q\nwant: %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderBeautified(t *testing.T) {
+	db, _ := openTest(t, `<mapper>
+<select id="find">
+	select *
+	from users
+	where <if test="name != nil">name = #{name} and</if>
+		note = 'a   b'
+</select>
+</mapper>`)
+
+	statement, _, err := db.Mapper(`find`).Render(Args{`name`: `a`})
+	if err != nil {
+		t.Fatalf(`Render() error = %v`, err)
+	}
+	if want := `select * from users where name = ? and note = 'a   b'`; statement != want {
+		t.Errorf("Render()\n got: %q\nwant: %q", statement, want)
+	}
+}
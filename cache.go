@@ -0,0 +1,316 @@
+package gobatis
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"database/sql/driver"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Cache second-level cache of select results, shared by the statements of the same namespace
+// namespace is the namespace attribute of mapper, or the xml file path if not set.
+type Cache interface {
+	// Get return the cached value of key in namespace
+	Get(namespace, key string) (any, bool)
+	// Set cache value of key in namespace, never expired if ttl <= 0
+	Set(namespace, key string, value any, ttl time.Duration)
+	// Invalidate remove all the cached values in namespace
+	Invalidate(namespace string)
+}
+
+// MemoryCache in-memory Cache with LRU eviction and ttl expiration
+type MemoryCache struct {
+	mu         sync.Mutex
+	size       int
+	lru        *list.List
+	namespaces map[string]map[string]*list.Element
+}
+
+type memoryEntry struct {
+	namespace string
+	key       string
+	value     any
+	expireAt  time.Time
+}
+
+// NewMemoryCache create in-memory cache holds at most size values
+func NewMemoryCache(size int) *MemoryCache {
+	return &MemoryCache{
+		size:       size,
+		lru:        list.New(),
+		namespaces: make(map[string]map[string]*list.Element),
+	}
+}
+
+func (c *MemoryCache) Get(namespace, key string) (any, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.namespaces[namespace][key]
+	if !ok {
+		return nil, false
+	}
+	entry := el.Value.(*memoryEntry)
+	if !entry.expireAt.IsZero() && time.Now().After(entry.expireAt) {
+		c.remove(el)
+		return nil, false
+	}
+	c.lru.MoveToFront(el)
+	return entry.value, true
+}
+
+func (c *MemoryCache) Set(namespace, key string, value any, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry := &memoryEntry{namespace: namespace, key: key, value: value}
+	if ttl > 0 {
+		entry.expireAt = time.Now().Add(ttl)
+	}
+
+	keys, ok := c.namespaces[namespace]
+	if !ok {
+		keys = make(map[string]*list.Element)
+		c.namespaces[namespace] = keys
+	}
+	if el, ok := keys[key]; ok {
+		el.Value = entry
+		c.lru.MoveToFront(el)
+		return
+	}
+	keys[key] = c.lru.PushFront(entry)
+	for c.size > 0 && c.lru.Len() > c.size {
+		c.remove(c.lru.Back())
+	}
+}
+
+func (c *MemoryCache) Invalidate(namespace string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, el := range c.namespaces[namespace] {
+		c.lru.Remove(el)
+	}
+	delete(c.namespaces, namespace)
+}
+
+// remove the element, must be called with lock held
+func (c *MemoryCache) remove(el *list.Element) {
+	entry := c.lru.Remove(el).(*memoryEntry)
+	keys := c.namespaces[entry.namespace]
+	delete(keys, entry.key)
+	if len(keys) == 0 {
+		delete(c.namespaces, entry.namespace)
+	}
+}
+
+// txInvalidation namespaces written in transaction, invalidated after commit
+type txInvalidation struct {
+	mu         sync.Mutex
+	namespaces map[string]bool
+}
+
+func (t *txInvalidation) add(namespace string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.namespaces[namespace] = true
+}
+
+// written report whether the namespace is written in transaction
+func (t *txInvalidation) written(namespace string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.namespaces[namespace]
+}
+
+// flush invalidate the written namespaces
+func (t *txInvalidation) flush(cache Cache) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for namespace := range t.namespaces {
+		cache.Invalidate(namespace)
+	}
+	t.namespaces = nil
+}
+
+// cacheTTL return ttl of the select mapper, and whether it's cacheable
+func (b *DB) cacheTTL() (time.Duration, bool, error) {
+	if b.cache == nil || b.mapperType != mapperSelect {
+		return 0, false, nil
+	}
+	if value, _ := b.mapperAttr(CacheKey); !strings.EqualFold(value, `true`) {
+		return 0, false, nil
+	}
+	value, ok := b.mapperAttr(TTLKey)
+	if !ok || value == `` {
+		return 0, true, nil
+	}
+	ttl, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, false, fmt.Errorf(`gobatis: invalid ttl="%s": %w`, value, err)
+	}
+	return ttl, true, nil
+}
+
+// cacheKey key of the select result, statement id, sql, args and the type of dest,
+// the args are encoded as their type and json value. false if any arg can't be encoded.
+func (b *DB) cacheKey(dest any) (string, bool) {
+	hash := sha256.New()
+	fmt.Fprintf(hash, "%s\x00%s\x00%s", b.mapperId, b.bindVars.stateSql, reflect.TypeOf(dest))
+	for _, arg := range b.bindVars.args {
+		value, err := canonicalArg(arg)
+		if err != nil {
+			return ``, false
+		}
+		data, err := json.Marshal(value)
+		if err != nil {
+			return ``, false
+		}
+		fmt.Fprintf(hash, "\x00%T:%s", value, data)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), true
+}
+
+// canonicalArg dereference the pointers and resolve the driver.Valuer of arg,
+// so the equal args produce the same key wherever they are stored.
+func canonicalArg(arg any) (any, error) {
+	valued := false
+	for {
+		rv := reflect.ValueOf(arg)
+		if rv.Kind() == reflect.Ptr && rv.IsNil() {
+			return nil, nil
+		}
+		if valuer, ok := arg.(driver.Valuer); ok && !valued {
+			value, err := valuer.Value()
+			if err != nil {
+				return nil, err
+			}
+			arg, valued = value, true
+			continue
+		}
+		if rv.Kind() != reflect.Ptr {
+			return arg, nil
+		}
+		arg = rv.Elem().Interface()
+	}
+}
+
+// loadCache copy the cached result into dest, appended if dest is slice
+func (b *DB) loadCache(namespace, key string, dest any) bool {
+	cached, ok := b.cache.Get(namespace, key)
+	if !ok {
+		return false
+	}
+	cv, dv := reflect.ValueOf(cached), reflect.ValueOf(dest).Elem()
+	if !cv.IsValid() || cv.Type() != dv.Type() {
+		return false
+	}
+	mergeResult(dv, cloneValue(cv))
+	return true
+}
+
+// mergeResult put the result value into dv, the slice result is appended to dv like scanning
+func mergeResult(dv, value reflect.Value) {
+	if dv.Kind() == reflect.Slice {
+		value = reflect.AppendSlice(dv, value)
+	}
+	dv.Set(value)
+}
+
+// invalidateCache invalidate the namespace of the write mapper, deferred until commit in transaction
+func (b *DB) invalidateCache() {
+	if b.cache == nil || b.mapper == nil {
+		return
+	}
+	switch b.mapperType {
+	case mapperInsert, mapperUpdate, mapperDelete:
+	default:
+		return
+	}
+
+	namespace, _ := b.mapperAttr(NamespaceKey)
+	if b.tx != nil && b.txInvalidation != nil {
+		b.txInvalidation.add(namespace)
+		return
+	}
+	b.cache.Invalidate(namespace)
+}
+
+// cloneValue deep copy v, so the cached values are not modified by callers
+func cloneValue(v reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return v
+		}
+		pv := reflect.New(v.Type().Elem())
+		pv.Elem().Set(cloneValue(v.Elem()))
+		return pv
+	case reflect.Interface:
+		if v.IsNil() {
+			return v
+		}
+		iv := reflect.New(v.Type()).Elem()
+		iv.Set(cloneValue(v.Elem()))
+		return iv
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		sv := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		if plainKind(v.Type().Elem().Kind()) {
+			reflect.Copy(sv, v)
+			return sv
+		}
+		for i := 0; i < v.Len(); i++ {
+			sv.Index(i).Set(cloneValue(v.Index(i)))
+		}
+		return sv
+	case reflect.Array:
+		av := reflect.New(v.Type()).Elem()
+		for i := 0; i < v.Len(); i++ {
+			av.Index(i).Set(cloneValue(v.Index(i)))
+		}
+		return av
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+		mv := reflect.MakeMapWithSize(v.Type(), v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			mv.SetMapIndex(iter.Key(), cloneValue(iter.Value()))
+		}
+		return mv
+	case reflect.Struct:
+		// the unexported fields are copied as they are
+		sv := reflect.New(v.Type()).Elem()
+		sv.Set(v)
+		for i := 0; i < v.NumField(); i++ {
+			if f := sv.Field(i); f.CanSet() {
+				f.Set(cloneValue(v.Field(i)))
+			}
+		}
+		return sv
+	default:
+		return v
+	}
+}
+
+// plainKind report whether the values of kind contain no references
+func plainKind(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.Bool, reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64, reflect.Complex64, reflect.Complex128:
+		return true
+	}
+	return false
+}
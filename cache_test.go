@@ -0,0 +1,304 @@
+package gobatis
+
+import (
+	"database/sql/driver"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestMemoryCache(t *testing.T) {
+	c := NewMemoryCache(2)
+	c.Set(`users`, `a`, 1, 0)
+	c.Set(`users`, `b`, 2, 0)
+
+	// a is used recently, b is evicted by c
+	if v, ok := c.Get(`users`, `a`); !ok || v != 1 {
+		t.Fatalf(`Get(a) = %v, %v, want 1`, v, ok)
+	}
+	c.Set(`orders`, `c`, 3, 0)
+	if _, ok := c.Get(`users`, `b`); ok {
+		t.Error(`Get(b) found the evicted value`)
+	}
+	if v, ok := c.Get(`orders`, `c`); !ok || v != 3 {
+		t.Errorf(`Get(c) = %v, %v, want 3`, v, ok)
+	}
+
+	// replace the value of existing key without eviction
+	c.Set(`users`, `a`, 4, 0)
+	if v, _ := c.Get(`users`, `a`); v != 4 {
+		t.Errorf(`Get(a) = %v, want 4`, v)
+	}
+	if v, ok := c.Get(`orders`, `c`); !ok || v != 3 {
+		t.Errorf(`Get(c) = %v, %v, want 3`, v, ok)
+	}
+}
+
+func TestMemoryCacheTTL(t *testing.T) {
+	c := NewMemoryCache(0)
+	c.Set(`users`, `expired`, 1, time.Nanosecond)
+	c.Set(`users`, `forever`, 2, 0)
+	time.Sleep(time.Millisecond)
+
+	if _, ok := c.Get(`users`, `expired`); ok {
+		t.Error(`Get() found the expired value`)
+	}
+	if v, ok := c.Get(`users`, `forever`); !ok || v != 2 {
+		t.Errorf(`Get() = %v, %v, want 2`, v, ok)
+	}
+	if c.lru.Len() != 1 {
+		t.Errorf(`expired value not removed, %d values cached`, c.lru.Len())
+	}
+}
+
+func TestMemoryCacheInvalidate(t *testing.T) {
+	c := NewMemoryCache(10)
+	c.Set(`users`, `a`, 1, 0)
+	c.Set(`users`, `b`, 2, 0)
+	c.Set(`orders`, `a`, 3, 0)
+
+	c.Invalidate(`users`)
+	if _, ok := c.Get(`users`, `a`); ok {
+		t.Error(`Get() found the invalidated value`)
+	}
+	if v, ok := c.Get(`orders`, `a`); !ok || v != 3 {
+		t.Errorf(`Get() of other namespace = %v, %v, want 3`, v, ok)
+	}
+	if c.lru.Len() != 1 || len(c.namespaces) != 1 {
+		t.Errorf(`invalidated values not removed, %d values in %d namespaces`, c.lru.Len(), len(c.namespaces))
+	}
+}
+
+const cacheMapper = `<mapper namespace="users">
+<select id="find" cache="true" ttl="1m">select * from users where id = #{id}</select>
+<select id="uncached">select * from users where id = #{id}</select>
+<update id="update">update users set name = #{name} where id = #{id}</update>
+</mapper>`
+
+const cacheQuery = `select * from users where id = ?`
+
+func TestFindCache(t *testing.T) {
+	db, server := openTest(t, cacheMapper, WithCache(NewMemoryCache(10)))
+	server.setRows([]string{`id`, `name`}, []driver.Value{int64(1), `a`})
+
+	for i := 0; i < 3; i++ {
+		var users []scanUser
+		if err := db.Mapper(`find`).Args(Args{`id`: 1}).Find(&users).Error; err != nil {
+			t.Fatalf(`Find() error = %v`, err)
+		}
+		if len(users) != 1 || users[0].Name != `a` {
+			t.Fatalf(`Find() = %+v`, users)
+		}
+		// the cached value is copied, modifying the result doesn't change the cache
+		users[0].Name = `modified`
+	}
+	if n := server.count(cacheQuery); n != 1 {
+		t.Errorf(`queried %d times, want 1`, n)
+	}
+
+	// other args and uncached selects query the database
+	var users []scanUser
+	db.Mapper(`find`).Args(Args{`id`: 2}).Find(&users)
+	db.Mapper(`uncached`).Args(Args{`id`: 1}).Find(&users)
+	if n := server.count(cacheQuery); n != 3 {
+		t.Errorf(`queried %d times, want 3`, n)
+	}
+
+	// the writes of namespace invalidate the cache
+	if err := db.Mapper(`update`).Args(Args{`id`: 1, `name`: `b`}).Execute().Error; err != nil {
+		t.Fatalf(`Execute() error = %v`, err)
+	}
+	db.Mapper(`find`).Args(Args{`id`: 1}).Find(&users)
+	if n := server.count(cacheQuery); n != 4 {
+		t.Errorf(`queried %d times after update, want 4`, n)
+	}
+}
+
+func TestFindCacheTransaction(t *testing.T) {
+	db, server := openTest(t, cacheMapper, WithCache(NewMemoryCache(10)))
+	server.setRows([]string{`id`, `name`}, []driver.Value{int64(1), `a`})
+	find := func(db *DB) {
+		var users []scanUser
+		if err := db.Mapper(`find`).Args(Args{`id`: 1}).Find(&users).Error; err != nil {
+			t.Fatalf(`Find() error = %v`, err)
+		}
+	}
+	update := func(db *DB) error {
+		return db.Mapper(`update`).Args(Args{`id`: 1, `name`: `b`}).Execute().Error
+	}
+
+	find(db)
+	rollback := errors.New(`rollback`)
+	err := db.Transaction(func(tx *DB) error {
+		if err := update(tx); err != nil {
+			return err
+		}
+		// the namespace written in transaction bypass the cache
+		find(tx)
+		return rollback
+	})
+	if err != rollback {
+		t.Fatalf(`Transaction() error = %v`, err)
+	}
+	// rollback doesn't invalidate the cache
+	find(db)
+	if n := server.count(cacheQuery); n != 2 {
+		t.Errorf(`queried %d times, want 2`, n)
+	}
+
+	if err := db.Transaction(update); err != nil {
+		t.Fatalf(`Transaction() error = %v`, err)
+	}
+	find(db)
+	if n := server.count(cacheQuery); n != 3 {
+		t.Errorf(`queried %d times after commit, want 3`, n)
+	}
+}
+
+func TestArgsDeferQuery(t *testing.T) {
+	db, server := openTest(t, cacheMapper)
+
+	// the select is run by Find
+	ndb := db.Mapper(`uncached`).Args(Args{`id`: 1})
+	if ndb.Error != nil {
+		t.Fatalf(`Args() error = %v`, ndb.Error)
+	}
+	if n := server.count(cacheQuery); n != 0 {
+		t.Errorf(`Args() queried %d times`, n)
+	}
+	var users []scanUser
+	if err := ndb.Find(&users).Error; err != nil {
+		t.Fatalf(`Find() error = %v`, err)
+	}
+	if n := server.count(cacheQuery); n != 1 {
+		t.Errorf(`Find() queried %d times, want 1`, n)
+	}
+}
+
+func TestBindQueries(t *testing.T) {
+	db, server := openTest(t, cacheMapper, WithCache(NewMemoryCache(10)))
+	server.setRows([]string{`id`, `name`}, []driver.Value{int64(1), `a`})
+
+	// the select is run by Bind, and the rows are scanned by Find, the cache is not used
+	for i := 0; i < 2; i++ {
+		ndb := db.Mapper(`find`).Bind(Args{`id`: 1})
+		if ndb.Error != nil {
+			t.Fatalf(`Bind() error = %v`, ndb.Error)
+		}
+		if n := server.count(cacheQuery); n != i+1 {
+			t.Errorf(`Bind() queried %d times, want %d`, n, i+1)
+		}
+		var users []scanUser
+		if err := ndb.Find(&users).Error; err != nil {
+			t.Fatalf(`Find() error = %v`, err)
+		}
+		if len(users) != 1 || users[0].Name != `a` {
+			t.Errorf(`Find() = %+v`, users)
+		}
+	}
+	if n := server.count(cacheQuery); n != 2 {
+		t.Errorf(`queried %d times, want 2`, n)
+	}
+
+	// the writes are executed by Execute
+	if err := db.Mapper(`update`).Bind(Args{`id`: 1, `name`: `b`}).Execute().Error; err != nil {
+		t.Fatalf(`Execute() error = %v`, err)
+	}
+	if n := len(server.log()); n != 3 {
+		t.Errorf(`%d statements executed, want 3`, n)
+	}
+}
+
+func TestMergeResult(t *testing.T) {
+	// the cached rows are appended to the rows of dest, as the slice scanned from database
+	dest := []int{1}
+	mergeResult(reflect.ValueOf(&dest).Elem(), reflect.ValueOf([]int{2, 3}))
+	if !reflect.DeepEqual(dest, []int{1, 2, 3}) {
+		t.Errorf(`mergeResult() = %v, want [1 2 3]`, dest)
+	}
+
+	var one struct{ ID int }
+	mergeResult(reflect.ValueOf(&one).Elem(), reflect.ValueOf(struct{ ID int }{ID: 7}))
+	if one.ID != 7 {
+		t.Errorf(`mergeResult() = %v, want 7`, one.ID)
+	}
+}
+
+func TestFindCacheAppend(t *testing.T) {
+	db, server := openTest(t, cacheMapper, WithCache(NewMemoryCache(10)))
+	server.setRows([]string{`id`, `name`}, []driver.Value{int64(1), `a`})
+
+	// the elements already in dest are not cached, the cached rows are appended like scanning
+	for i := 0; i < 2; i++ {
+		users := []scanUser{{Id: int64(100 + i)}}
+		if err := db.Mapper(`find`).Args(Args{`id`: 1}).Find(&users).Error; err != nil {
+			t.Fatalf(`Find() error = %v`, err)
+		}
+		if len(users) != 2 || users[0].Id != int64(100+i) || users[1].Id != 1 {
+			t.Errorf(`Find() = %+v`, users)
+		}
+	}
+	if n := server.count(cacheQuery); n != 1 {
+		t.Errorf(`queried %d times, want 1`, n)
+	}
+}
+
+// testValuer driver.Valuer returning its value
+type testValuer struct{ v string }
+
+func (t testValuer) Value() (driver.Value, error) { return t.v, nil }
+
+func TestCacheKey(t *testing.T) {
+	keyOf := func(args ...any) string {
+		db := &DB{mapperId: `find`, bindVars: &BindVar{stateSql: `select * from users where id = ?`, args: args}}
+		key, ok := db.cacheKey(&[]scanUser{})
+		if !ok {
+			t.Fatalf(`cacheKey(%v) not ok`, args)
+		}
+		return key
+	}
+	one, two, a := int64(1), int64(1), `a`
+	tests := []struct {
+		name  string
+		x, y  []any
+		equal bool
+	}{
+		{`pointers of equal values`, []any{&one}, []any{&two}, true},
+		{`pointer and value`, []any{&one, &a}, []any{int64(1), `a`}, true},
+		{`nil pointer and nil`, []any{(*int64)(nil)}, []any{nil}, true},
+		{`valuer and value`, []any{testValuer{`a`}, &testValuer{`a`}}, []any{`a`, `a`}, true},
+		{`different values`, []any{int64(1)}, []any{int64(2)}, false},
+		{`different types`, []any{int64(1)}, []any{`1`}, false},
+		{`args boundary`, []any{`a:b`}, []any{`a`, `b`}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if equal := keyOf(tt.x...) == keyOf(tt.y...); equal != tt.equal {
+				t.Errorf(`cacheKey(%v) == cacheKey(%v) is %v, want %v`, tt.x, tt.y, equal, tt.equal)
+			}
+		})
+	}
+
+	db := &DB{bindVars: &BindVar{args: []any{func() {}}}}
+	if _, ok := db.cacheKey(&[]scanUser{}); ok {
+		t.Error(`cacheKey() of func arg is ok`)
+	}
+}
+
+func TestFindCachePointerArgs(t *testing.T) {
+	db, server := openTest(t, cacheMapper, WithCache(NewMemoryCache(10)))
+	server.setRows([]string{`id`, `name`}, []driver.Value{int64(1), `a`})
+
+	// the args stored at different addresses share the cached result
+	for i := 0; i < 3; i++ {
+		id := 1
+		var users []scanUser
+		if err := db.Mapper(`find`).Args(Args{`id`: &id}).Find(&users).Error; err != nil {
+			t.Fatalf(`Find() error = %v`, err)
+		}
+	}
+	if n := server.count(cacheQuery); n != 1 {
+		t.Errorf(`queried %d times, want 1`, n)
+	}
+}
@@ -0,0 +1,57 @@
+package gobatis
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+type tenantKey struct{}
+
+func TestContextArgs(t *testing.T) {
+	var calls int
+	db, _ := openTest(t, `<mapper>
+<select id="find">select * from orders where tenant_id = #{_ctx.tenant_id}<if test="_ctx.admin"> or public</if></select>
+</mapper>`, WithContextArgs(func(ctx context.Context) Args {
+		calls++
+		tenant, ok := ctx.Value(tenantKey{}).(int)
+		if !ok {
+			return nil
+		}
+		return Args{`tenant_id`: tenant, `admin`: tenant == 0}
+	}))
+
+	tests := []struct {
+		name      string
+		ctx       context.Context
+		variables Args
+		statement string
+		args      []interface{}
+	}{
+		{`tenant`, context.WithValue(context.Background(), tenantKey{}, 7), Args{}, `select * from orders where tenant_id = ?`, []interface{}{7}},
+		{`admin`, context.WithValue(context.Background(), tenantKey{}, 0), Args{}, `select * from orders where tenant_id = ? or public`, []interface{}{0}},
+		{`args take precedence`, context.WithValue(context.Background(), tenantKey{}, 7), Args{`_ctx`: Args{`tenant_id`: 9, `admin`: false}}, `select * from orders where tenant_id = ?`, []interface{}{9}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			statement, args, err := db.WithContext(tt.ctx).Mapper(`find`).Render(tt.variables)
+			if err != nil {
+				t.Fatalf(`Render() error = %v`, err)
+			}
+			if statement != tt.statement {
+				t.Errorf("Render()\n got: %q\nwant: %q", statement, tt.statement)
+			}
+			if !reflect.DeepEqual(args, tt.args) {
+				t.Errorf(`Render() args = %#v, want %#v`, args, tt.args)
+			}
+		})
+	}
+	if calls != len(tests) {
+		t.Errorf(`context args derived %d times, want %d`, calls, len(tests))
+	}
+
+	// the context without variables
+	if _, _, err := db.Mapper(`find`).Render(Args{}); err == nil {
+		t.Error(`Render() without _ctx error = nil`)
+	}
+}
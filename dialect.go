@@ -0,0 +1,242 @@
+package gobatis
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Dialect the syntax differences of databases, registered by driver name with RegisterDialect
+// the custom dialect can embed a built-in one to override part of it, forexample:
+//
+//	type cockroachDialect struct{ gobatis.PostgresDialect }
+//
+//	gobatis.RegisterDialect(`cockroach`, cockroachDialect{})
+type Dialect interface {
+	// Placeholder the placeholder of the n-th parameter starting from 1, forexample: ?, $1, @p1, :1
+	Placeholder(n int) string
+	// Quote quote the identifier, forexample: "users", `users`, [users]
+	Quote(identifier string) string
+	// Limit the clause of limit and offset, the offset is omitted if <= 0
+	Limit(limit, offset int64) string
+	// Returning report whether insert, update and delete support the RETURNING clause
+	Returning() bool
+	// Savepoint, RollbackToSavepoint and ReleaseSavepoint statements of the savepoint name,
+	// ReleaseSavepoint return empty if not supported.
+	Savepoint(name string) string
+	RollbackToSavepoint(name string) string
+	ReleaseSavepoint(name string) string
+	// MaxParams max placeholders of one statement
+	MaxParams() int
+	// System the well-known name of the database, the db.system.name of tracing, forexample: postgresql
+	System() string
+}
+
+var (
+	dialectsMu sync.RWMutex
+	dialects   = map[string]Dialect{}
+
+	// defaultDialect dialect of the drivers not registered
+	defaultDialect Dialect = StandardDialect{}
+)
+
+func init() {
+	for _, name := range []string{`postgres`, `pg`, `pgx`, `pgx/v5`} {
+		RegisterDialect(name, PostgresDialect{})
+	}
+	RegisterDialect(`mysql`, MysqlDialect{})
+	for _, name := range []string{`sqlite`, `sqlite3`} {
+		RegisterDialect(name, SqliteDialect{})
+	}
+	for _, name := range []string{`sqlserver`, `mssql`} {
+		RegisterDialect(name, SqlserverDialect{})
+	}
+	for _, name := range []string{`godror`, `goracle`, `oracle`} {
+		RegisterDialect(name, OracleDialect{})
+	}
+}
+
+// RegisterDialect register dialect of the driver name, or the type attribute of mapper,
+// the registered one is replaced. the drivers not registered use StandardDialect.
+func RegisterDialect(driverName string, dialect Dialect) {
+	dialectsMu.Lock()
+	defer dialectsMu.Unlock()
+	dialects[strings.ToLower(driverName)] = dialect
+}
+
+// dialectOf return the dialect of the driver name
+func dialectOf(driverName string) Dialect {
+	dialectsMu.RLock()
+	defer dialectsMu.RUnlock()
+	if dialect, ok := dialects[strings.ToLower(driverName)]; ok {
+		return dialect
+	}
+	return defaultDialect
+}
+
+// Dialect return the dialect of the driver opened
+func (b *DB) Dialect() Dialect {
+	return dialectOf(b.driverName)
+}
+
+// exprDialect the dialect visible to the mapper expressions as variable dialect, forexample:
+//
+//	select * from ${dialect.Quote(table)} order by id ${dialect.Limit(size, (page - 1) * size)}
+//	<if test="dialect.Returning()">returning id</if>
+type exprDialect struct {
+	Dialect
+}
+
+// Limit accept the integers of any size, the integers of expressions are int
+func (d exprDialect) Limit(limit, offset any) (string, error) {
+	l, err := int64Of(limit)
+	if err != nil {
+		return ``, err
+	}
+	o, err := int64Of(offset)
+	if err != nil {
+		return ``, err
+	}
+	return d.Dialect.Limit(l, o), nil
+}
+
+// int64Of convert the integer v to int64
+func int64Of(v any) (int64, error) {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int(), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int64(rv.Uint()), nil
+	}
+	return 0, fmt.Errorf(`gobatis: dialect: %T is not integer`, v)
+}
+
+// literalStyleOf return the literal flavor of dialect, the custom dialects not embedding
+// the built-in ones use the standard sql literals.
+func literalStyleOf(dialect Dialect) int {
+	if d, ok := dialect.(interface{ literalStyle() int }); ok {
+		return d.literalStyle()
+	}
+	return literalStandard
+}
+
+// quoteIdentifier quote identifier with quote, the quote inside is escaped by doubling it
+func quoteIdentifier(identifier, open, close string) string {
+	return open + strings.ReplaceAll(identifier, close, close+close) + close
+}
+
+// limitOffset LIMIT n OFFSET m clause
+func limitOffset(limit, offset int64) string {
+	clause := `LIMIT ` + strconv.FormatInt(limit, 10)
+	if offset > 0 {
+		clause += ` OFFSET ` + strconv.FormatInt(offset, 10)
+	}
+	return clause
+}
+
+// offsetFetch OFFSET m ROWS FETCH NEXT n ROWS ONLY clause of standard sql, sqlserver and oracle
+func offsetFetch(limit, offset int64) string {
+	if offset < 0 {
+		offset = 0
+	}
+	return `OFFSET ` + strconv.FormatInt(offset, 10) + ` ROWS FETCH NEXT ` + strconv.FormatInt(limit, 10) + ` ROWS ONLY`
+}
+
+// StandardDialect standard sql, ? as placeholders, the dialect of the drivers not registered
+type StandardDialect struct{}
+
+func (StandardDialect) Placeholder(n int) string         { return `?` }
+func (StandardDialect) Quote(identifier string) string   { return quoteIdentifier(identifier, `"`, `"`) }
+func (StandardDialect) Limit(limit, offset int64) string { return offsetFetch(limit, offset) }
+func (StandardDialect) Returning() bool                  { return false }
+func (StandardDialect) Savepoint(name string) string     { return `SAVEPOINT ` + name }
+func (StandardDialect) RollbackToSavepoint(name string) string {
+	return `ROLLBACK TO SAVEPOINT ` + name
+}
+func (StandardDialect) ReleaseSavepoint(name string) string { return `RELEASE SAVEPOINT ` + name }
+func (StandardDialect) MaxParams() int                      { return 65535 }
+func (StandardDialect) System() string                      { return `other_sql` }
+func (StandardDialect) literalStyle() int                   { return literalStandard }
+
+// PostgresDialect postgres, $1, $2, ... as placeholders
+type PostgresDialect struct{}
+
+func (PostgresDialect) Placeholder(n int) string         { return `$` + strconv.Itoa(n) }
+func (PostgresDialect) Quote(identifier string) string   { return quoteIdentifier(identifier, `"`, `"`) }
+func (PostgresDialect) Limit(limit, offset int64) string { return limitOffset(limit, offset) }
+func (PostgresDialect) Returning() bool                  { return true }
+func (PostgresDialect) Savepoint(name string) string     { return `SAVEPOINT ` + name }
+func (PostgresDialect) RollbackToSavepoint(name string) string {
+	return `ROLLBACK TO SAVEPOINT ` + name
+}
+func (PostgresDialect) ReleaseSavepoint(name string) string { return `RELEASE SAVEPOINT ` + name }
+func (PostgresDialect) MaxParams() int                      { return 65535 }
+func (PostgresDialect) System() string                      { return `postgresql` }
+func (PostgresDialect) literalStyle() int                   { return literalPostgres }
+
+// MysqlDialect mysql, ? as placeholders
+type MysqlDialect struct{}
+
+func (MysqlDialect) Placeholder(n int) string         { return `?` }
+func (MysqlDialect) Quote(identifier string) string   { return quoteIdentifier(identifier, "`", "`") }
+func (MysqlDialect) Limit(limit, offset int64) string { return limitOffset(limit, offset) }
+func (MysqlDialect) Returning() bool                  { return false }
+func (MysqlDialect) Savepoint(name string) string     { return `SAVEPOINT ` + name }
+func (MysqlDialect) RollbackToSavepoint(name string) string {
+	return `ROLLBACK TO SAVEPOINT ` + name
+}
+func (MysqlDialect) ReleaseSavepoint(name string) string { return `RELEASE SAVEPOINT ` + name }
+func (MysqlDialect) MaxParams() int                      { return 65535 }
+func (MysqlDialect) System() string                      { return `mysql` }
+func (MysqlDialect) literalStyle() int                   { return literalMysql }
+
+// SqliteDialect sqlite, ? as placeholders
+type SqliteDialect struct{}
+
+func (SqliteDialect) Placeholder(n int) string         { return `?` }
+func (SqliteDialect) Quote(identifier string) string   { return quoteIdentifier(identifier, `"`, `"`) }
+func (SqliteDialect) Limit(limit, offset int64) string { return limitOffset(limit, offset) }
+func (SqliteDialect) Returning() bool                  { return true }
+func (SqliteDialect) Savepoint(name string) string     { return `SAVEPOINT ` + name }
+func (SqliteDialect) RollbackToSavepoint(name string) string {
+	return `ROLLBACK TO SAVEPOINT ` + name
+}
+func (SqliteDialect) ReleaseSavepoint(name string) string { return `RELEASE SAVEPOINT ` + name }
+func (SqliteDialect) MaxParams() int                      { return 32766 }
+func (SqliteDialect) System() string                      { return `sqlite` }
+func (SqliteDialect) literalStyle() int                   { return literalStandard }
+
+// SqlserverDialect sqlserver, @p1, @p2, ... as placeholders
+type SqlserverDialect struct{}
+
+func (SqlserverDialect) Placeholder(n int) string         { return `@p` + strconv.Itoa(n) }
+func (SqlserverDialect) Quote(identifier string) string   { return quoteIdentifier(identifier, `[`, `]`) }
+func (SqlserverDialect) Limit(limit, offset int64) string { return offsetFetch(limit, offset) }
+func (SqlserverDialect) Returning() bool                  { return false }
+func (SqlserverDialect) Savepoint(name string) string     { return `SAVE TRANSACTION ` + name }
+func (SqlserverDialect) RollbackToSavepoint(name string) string {
+	return `ROLLBACK TRANSACTION ` + name
+}
+func (SqlserverDialect) ReleaseSavepoint(name string) string { return `` }
+func (SqlserverDialect) MaxParams() int                      { return 2100 }
+func (SqlserverDialect) System() string                      { return `microsoft.sql_server` }
+func (SqlserverDialect) literalStyle() int                   { return literalSqlserver }
+
+// OracleDialect oracle, :1, :2, ... as placeholders
+type OracleDialect struct{}
+
+func (OracleDialect) Placeholder(n int) string         { return `:` + strconv.Itoa(n) }
+func (OracleDialect) Quote(identifier string) string   { return quoteIdentifier(identifier, `"`, `"`) }
+func (OracleDialect) Limit(limit, offset int64) string { return offsetFetch(limit, offset) }
+func (OracleDialect) Returning() bool                  { return false }
+func (OracleDialect) Savepoint(name string) string     { return `SAVEPOINT ` + name }
+func (OracleDialect) RollbackToSavepoint(name string) string {
+	return `ROLLBACK TO SAVEPOINT ` + name
+}
+func (OracleDialect) ReleaseSavepoint(name string) string { return `` }
+func (OracleDialect) MaxParams() int                      { return 65535 }
+func (OracleDialect) System() string                      { return `oracle.db` }
+func (OracleDialect) literalStyle() int                   { return literalOracle }
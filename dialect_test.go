@@ -0,0 +1,208 @@
+package gobatis
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDialectPlaceholder(t *testing.T) {
+	tests := []struct {
+		typ       string
+		statement string
+	}{
+		{`postgres`, `select * from users where id = $1 and name in ($2, $3)`},
+		{`pgx`, `select * from users where id = $1 and name in ($2, $3)`},
+		{`mysql`, `select * from users where id = ? and name in (?, ?)`},
+		{`sqlite3`, `select * from users where id = ? and name in (?, ?)`},
+		{`sqlserver`, `select * from users where id = @p1 and name in (@p2, @p3)`},
+		{`godror`, `select * from users where id = :1 and name in (:2, :3)`},
+		{`unknown`, `select * from users where id = ? and name in (?, ?)`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.typ, func(t *testing.T) {
+			db, _ := openTest(t, fmt.Sprintf(`<mapper type="%s">
+<select id="find">select * from users where id = #{id} and name in (#{names})</select>
+</mapper>`, tt.typ))
+
+			statement, args, err := db.Mapper(`find`).Render(Args{`id`: 1, `names`: []string{`a`, `b`}})
+			if err != nil {
+				t.Fatalf(`Render() error = %v`, err)
+			}
+			if statement != tt.statement {
+				t.Errorf("Render() statement\n got: %q\nwant: %q", statement, tt.statement)
+			}
+			if len(args) != 3 {
+				t.Errorf(`Render() args = %v, want 3 args`, args)
+			}
+		})
+	}
+}
+
+func TestDialectOf(t *testing.T) {
+	tests := []struct {
+		driverName string
+		limit      string
+		returning  bool
+		system     string
+	}{
+		{`pgx/v5`, `LIMIT 10 OFFSET 20`, true, `postgresql`},
+		{`MySQL`, `LIMIT 10 OFFSET 20`, false, `mysql`},
+		{`sqlite`, `LIMIT 10 OFFSET 20`, true, `sqlite`},
+		{`mssql`, `OFFSET 20 ROWS FETCH NEXT 10 ROWS ONLY`, false, `microsoft.sql_server`},
+		{`oracle`, `OFFSET 20 ROWS FETCH NEXT 10 ROWS ONLY`, false, `oracle.db`},
+		{`unknown`, `OFFSET 20 ROWS FETCH NEXT 10 ROWS ONLY`, false, `other_sql`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.driverName, func(t *testing.T) {
+			dialect := dialectOf(tt.driverName)
+			if limit := dialect.Limit(10, 20); limit != tt.limit {
+				t.Errorf(`Limit() = %q, want %q`, limit, tt.limit)
+			}
+			if returning := dialect.Returning(); returning != tt.returning {
+				t.Errorf(`Returning() = %v, want %v`, returning, tt.returning)
+			}
+			if system := dialect.System(); system != tt.system {
+				t.Errorf(`System() = %q, want %q`, system, tt.system)
+			}
+		})
+	}
+}
+
+func TestDialectVariable(t *testing.T) {
+	tests := []struct {
+		typ       string
+		statement string
+	}{
+		{`postgres`, `select * from "users" order by id LIMIT 10 OFFSET 10 returning id`},
+		{`sqlserver`, `select * from [users] order by id OFFSET 10 ROWS FETCH NEXT 10 ROWS ONLY`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.typ, func(t *testing.T) {
+			db, _ := openTest(t, fmt.Sprintf(`<mapper type="%s">
+<select id="page">select * from ${dialect.Quote(table)} order by id ${dialect.Limit(size, (page - 1) * size)}<if test="dialect.Returning()"> returning id</if></select>
+</mapper>`, tt.typ))
+
+			statement, _, err := db.Mapper(`page`).Render(Args{`table`: `users`, `page`: 2, `size`: 10})
+			if err != nil {
+				t.Fatalf(`Render() error = %v`, err)
+			}
+			if statement != tt.statement {
+				t.Errorf("Render() statement\n got: %q\nwant: %q", statement, tt.statement)
+			}
+		})
+	}
+}
+
+func TestDialectQuote(t *testing.T) {
+	tests := []struct {
+		dialect Dialect
+		quoted  string
+	}{
+		{PostgresDialect{}, `"my""table"`},
+		{MysqlDialect{}, "`my\"table`"},
+		{SqlserverDialect{}, `[my"table]`},
+	}
+	for _, tt := range tests {
+		if quoted := tt.dialect.Quote(`my"table`); quoted != tt.quoted {
+			t.Errorf(`%T.Quote() = %q, want %q`, tt.dialect, quoted, tt.quoted)
+		}
+	}
+	if quoted := (SqlserverDialect{}).Quote(`a]b`); quoted != `[a]]b]` {
+		t.Errorf(`SqlserverDialect.Quote() = %q, want %q`, quoted, `[a]]b]`)
+	}
+}
+
+// testDialect postgres with the custom paging
+type testDialect struct{ PostgresDialect }
+
+func (testDialect) Limit(limit, offset int64) string {
+	return fmt.Sprintf(`LIMIT %d, %d`, offset, limit)
+}
+
+func TestRegisterDialect(t *testing.T) {
+	RegisterDialect(`gobatis-test-dialect`, testDialect{})
+	dialect := dialectOf(`GOBATIS-TEST-DIALECT`)
+	if limit := dialect.Limit(10, 20); limit != `LIMIT 20, 10` {
+		t.Errorf(`Limit() = %q`, limit)
+	}
+	if placeholder := dialect.Placeholder(2); placeholder != `$2` {
+		t.Errorf(`Placeholder() = %q`, placeholder)
+	}
+
+	db, _ := openTest(t, `<mapper type="gobatis-test-dialect"><select id="find">select * from users where id = #{id}</select></mapper>`)
+	if statement, _, err := db.Mapper(`find`).Render(Args{`id`: 1}); err != nil || statement != `select * from users where id = $1` {
+		t.Errorf(`Render() = %q, %v`, statement, err)
+	}
+}
+
+func TestSavepoint(t *testing.T) {
+	db, server := openTest(t, `<mapper>
+<insert id="insert">insert into users (name) values (#{name})</insert>
+</mapper>`)
+	insert := func(tx *DB, name string) error {
+		return tx.Mapper(`insert`).Args(Args{`name`: name}).Execute().Error
+	}
+
+	failed := errors.New(`failed`)
+	err := db.Transaction(func(tx *DB) error {
+		if err := insert(tx, `a`); err != nil {
+			return err
+		}
+		if err := tx.Savepoint(func(sp *DB) error {
+			if err := insert(sp, `b`); err != nil {
+				return err
+			}
+			return failed
+		}); err != failed {
+			t.Errorf(`Savepoint() error = %v, want %v`, err, failed)
+		}
+		return tx.Savepoint(func(sp *DB) error {
+			return insert(sp, `c`)
+		})
+	})
+	if err != nil {
+		t.Fatalf(`Transaction() error = %v`, err)
+	}
+
+	var got []string
+	names := map[string]bool{}
+	for _, statement := range server.log() {
+		// the savepoint names are generated, short enough for oracle and sqlserver
+		if fields := strings.Fields(statement); strings.HasPrefix(fields[len(fields)-1], `gobatis_sp`) {
+			if name := fields[len(fields)-1]; len(name) > 30 {
+				t.Errorf(`savepoint name %q is longer than 30 bytes`, name)
+			} else {
+				names[name] = true
+			}
+			statement = strings.Join(fields[:len(fields)-1], ` `)
+		}
+		got = append(got, statement)
+	}
+	if len(names) != 2 {
+		t.Errorf(`%d savepoint names, want 2`, len(names))
+	}
+	insertSql := `insert into users (name) values (?)`
+	want := []string{
+		`BEGIN`, insertSql,
+		`SAVEPOINT`, insertSql, `ROLLBACK TO SAVEPOINT`,
+		`SAVEPOINT`, insertSql, `RELEASE SAVEPOINT`,
+		`COMMIT`,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("executed\n got: %q\nwant: %q", got, want)
+	}
+}
+
+func TestSavepointWithoutTransaction(t *testing.T) {
+	db, server := openTest(t, `<mapper></mapper>`)
+
+	if err := db.Savepoint(func(tx *DB) error { return nil }); err != nil {
+		t.Fatalf(`Savepoint() error = %v`, err)
+	}
+	if got := server.log(); !reflect.DeepEqual(got, []string{`BEGIN`, `COMMIT`}) {
+		t.Errorf(`executed %q, want a transaction`, got)
+	}
+}
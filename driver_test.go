@@ -0,0 +1,263 @@
+package gobatis
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testDriverName the fake driver of the tests, the statements are recorded
+// and the queries are answered with the rows set by the tests, no database is needed.
+const testDriverName = `gobatistest`
+
+func init() {
+	sql.Register(testDriverName, testDriver{})
+}
+
+// testServers the fake databases keyed by dsn
+var testServers sync.Map
+
+// testServer the fake database of a dsn
+type testServer struct {
+	mu sync.Mutex
+	// statements the sql executed, queried and the transaction statements
+	statements []string
+	// columns and rows answered to the queries
+	columns []string
+	rows    [][]driver.Value
+	// err returned by the statements, fail returns the error of the statement if set
+	err  error
+	fail func(statement string) error
+	// delay of the statements, it's cancelled by the context
+	delay time.Duration
+	// prepared and closed statements
+	prepared, closed int
+	// down the ping fails
+	down bool
+}
+
+// testServerOf return the fake database of dsn
+func testServerOf(dsn string) *testServer {
+	server, _ := testServers.LoadOrStore(dsn, &testServer{})
+	return server.(*testServer)
+}
+
+// setRows answer the queries with columns and rows
+func (s *testServer) setRows(columns []string, rows ...[]driver.Value) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.columns, s.rows = columns, rows
+}
+
+func (s *testServer) setErr(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.err = err
+}
+
+// setFail fail the statements with the error returned by fail
+func (s *testServer) setFail(fail func(statement string) error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.fail = fail
+}
+
+func (s *testServer) setDelay(delay time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.delay = delay
+}
+
+func (s *testServer) setDown(down bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.down = down
+}
+
+// log return the recorded statements
+func (s *testServer) log() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.statements...)
+}
+
+// count return the times of statement recorded
+func (s *testServer) count(statement string) int {
+	n := 0
+	for _, recorded := range s.log() {
+		if recorded == statement {
+			n++
+		}
+	}
+	return n
+}
+
+func (s *testServer) record(statement string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.statements = append(s.statements, statement)
+}
+
+// run record the statement, wait for the delay and return the error set
+func (s *testServer) run(ctx context.Context, statement string) error {
+	s.record(statement)
+	s.mu.Lock()
+	delay, err, fail := s.delay, s.err, s.fail
+	s.mu.Unlock()
+	if fail != nil && err == nil {
+		err = fail(statement)
+	}
+
+	if delay > 0 {
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+	return err
+}
+
+type testDriver struct{}
+
+func (testDriver) Open(dsn string) (driver.Conn, error) {
+	return &testConn{server: testServerOf(dsn)}, nil
+}
+
+type testConn struct {
+	server *testServer
+}
+
+func (c *testConn) Prepare(query string) (driver.Stmt, error) {
+	c.server.mu.Lock()
+	defer c.server.mu.Unlock()
+	c.server.prepared++
+	return &testStmt{server: c.server, query: query}, nil
+}
+
+func (c *testConn) Close() error {
+	return nil
+}
+
+func (c *testConn) Begin() (driver.Tx, error) {
+	c.server.record(`BEGIN`)
+	return &testTx{server: c.server}, nil
+}
+
+func (c *testConn) Ping(ctx context.Context) error {
+	c.server.mu.Lock()
+	defer c.server.mu.Unlock()
+	if c.server.down {
+		return driver.ErrBadConn
+	}
+	return nil
+}
+
+type testTx struct {
+	server *testServer
+}
+
+func (tx *testTx) Commit() error {
+	tx.server.record(`COMMIT`)
+	return nil
+}
+
+func (tx *testTx) Rollback() error {
+	tx.server.record(`ROLLBACK`)
+	return nil
+}
+
+type testStmt struct {
+	server *testServer
+	query  string
+	closed bool
+}
+
+func (s *testStmt) Close() error {
+	s.server.mu.Lock()
+	defer s.server.mu.Unlock()
+	if !s.closed {
+		s.closed = true
+		s.server.closed++
+	}
+	return nil
+}
+
+func (s *testStmt) NumInput() int {
+	return -1
+}
+
+func (s *testStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return nil, errors.New(`gobatistest: use ExecContext`)
+}
+
+func (s *testStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return nil, errors.New(`gobatistest: use QueryContext`)
+}
+
+func (s *testStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	if err := s.server.run(ctx, s.query); err != nil {
+		return nil, err
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (s *testStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	if err := s.server.run(ctx, s.query); err != nil {
+		return nil, err
+	}
+	s.server.mu.Lock()
+	defer s.server.mu.Unlock()
+	return &testRows{columns: s.server.columns, rows: s.server.rows}, nil
+}
+
+type testRows struct {
+	columns []string
+	rows    [][]driver.Value
+}
+
+func (r *testRows) Columns() []string {
+	return r.columns
+}
+
+func (r *testRows) Close() error {
+	return nil
+}
+
+func (r *testRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
+
+// openTest open the fake database of the test with the mapper of content
+func openTest(t *testing.T, content string, opts ...func(*DB)) (*DB, *testServer) {
+	t.Helper()
+	mapper, err := ParseMapperFromBuffer([]byte(content))
+	if err != nil {
+		t.Fatalf(`parse mapper: %v`, err)
+	}
+
+	dsn := t.Name()
+	server := &testServer{}
+	testServers.Store(dsn, server)
+
+	db, err := Open(testDriverName, dsn, append([]func(*DB){WithMapper(mapper)}, opts...)...)
+	if err != nil {
+		t.Fatalf(`open: %v`, err)
+	}
+	t.Cleanup(func() {
+		_ = db.db.Close()
+	})
+	return db.WithContext(context.Background()), server
+}
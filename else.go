@@ -39,7 +39,11 @@ func (m *Else) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
 				m.Children = append(m.Children, ele)
 			}
 		case xml.CharData:
-			m.Children = append(m.Children, el.Copy())
+			if text, err := NewText(el.Copy()); err != nil {
+				return err
+			} else {
+				m.Children = append(m.Children, text)
+			}
 		case xml.EndElement:
 			return nil
 		case xml.Comment, xml.ProcInst, xml.Directive:
@@ -9,8 +9,10 @@ import (
 	"io/fs"
 	"path/filepath"
 	"reflect"
+	"sort"
+	"strconv"
 	"strings"
-	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -24,6 +26,7 @@ var (
 	ErrorDestCantBeNil             = errors.New("gobatis: dest can't be nil")
 	ErrorBindArgsNeedBeMapOrStruct = errors.New(`gobatis: Args need be map or struct`)
 	ErrorPreparedStatementsEmpty   = errors.New(`gobatis: prepared statements empty`)
+	ErrorStrictScan                = errors.New(`gobatis: strict scan`)
 
 	timeType    = reflect.TypeOf(time.Time{})
 	timePtrType = reflect.TypeOf(&time.Time{})
@@ -74,6 +77,7 @@ type Logger interface {
 	// param sql: prepared sql
 	// param args: prepared sql args.
 	// when level is LogLevelError, args[0] is error.
+	// see StructuredLogger for the logs with mapper id, statement type, rows and error.
 	Log(ctx context.Context, level int, duration int64, sql string, args ...any)
 }
 
@@ -87,7 +91,7 @@ type DB struct {
 	insertMapper map[string]*Insert
 	updateMapper map[string]*Update
 	deleteMapper map[string]*Delete
-	sqlMapper    map[string]string
+	sqlMapper    map[string]*Sql
 
 	// error information.
 	LastInserId  int64
@@ -97,15 +101,19 @@ type DB struct {
 	// mapper
 	mapper     BindHandler
 	mapperType int
+	mapperId   string
 
 	// bindVars
 	bindVars *BindVar
 
 	// rows scanner
 	rows *sql.Rows
+	// rowsEnd end the span of the rows opened by RawQuery or Bind, called after the rows closed
+	rowsEnd SpanEnd
 
 	// logger
-	logger Logger
+	logger           Logger
+	structuredLogger StructuredLogger
 
 	// context use
 	ctx context.Context
@@ -116,6 +124,58 @@ type DB struct {
 	// inner use
 	recordLog bool
 
+	// strict scan mode
+	strictScan bool
+
+	// keep the whitespaces of rendered statements as they are
+	noBeautify bool
+
+	// prepared statements cache, nil if disabled
+	stmts *stmtCache
+
+	// second-level cache of select results, nil if disabled
+	cache Cache
+
+	// share the result of concurrent identical selects
+	sharedQueries bool
+	flights       *flightGroup
+
+	// timeout of the statements without timeout attribute, 0 means no timeout
+	defaultTimeout time.Duration
+	// deadline of the running statement, nil if no timeout
+	deadline *stmtDeadline
+
+	// functions and constants of the mapper expressions
+	exprEnv map[string]any
+	// contextArgs variables derived from the context of statements, bound to _ctx
+	contextArgs func(ctx context.Context) Args
+
+	// interceptors around the phases of statements, called in order
+	interceptors []Interceptor
+
+	// tracer of the statements and transactions, nil if disabled
+	tracer Tracer
+
+	// metrics of the statements, nil if disabled
+	stats *statsRegistry
+
+	// slow query detection, the statements slower than threshold or their slow attribute invoke slowHandler
+	slowThreshold time.Duration
+	slowHandler   SlowQueryHandler
+
+	// fraction of the debug logs sent to logger
+	debugSampling float64
+
+	// read replicas of the selects outside transaction, nil if not set
+	replicas *replicaSet
+	// route all the statements to primary
+	usePrimary bool
+
+	// namespaces to be invalidated after the transaction committed
+	txInvalidation *txInvalidation
+	// prepared statements bound to the transaction, nil if not in transaction
+	txStmts *txStmts
+
 	// startTime
 	startTime time.Time
 }
@@ -127,6 +187,168 @@ func WithLogger(logger Logger) func(*DB) {
 	}
 }
 
+// WithStructuredLogger set logger receiving the events with mapper id, statement type, rows and error,
+// it can be used together with WithLogger.
+func WithStructuredLogger(logger StructuredLogger) func(*DB) {
+	return func(db *DB) {
+		db.structuredLogger = logger
+	}
+}
+
+// WithStrictScan fail Find when result columns don't match any struct field,
+// or struct fields without omitempty option don't match any result column.
+// also enabled per select with attribute strict="true".
+func WithStrictScan() func(*DB) {
+	return func(db *DB) {
+		db.strictScan = true
+	}
+}
+
+// WithoutBeautify disable the whitespace normalization of rendered statements
+func WithoutBeautify() func(*DB) {
+	return func(db *DB) {
+		db.noBeautify = true
+	}
+}
+
+// WithStmtCache cache at most size prepared statements, keyed by the statement sql
+// the least recently used statements are closed when the cache is full.
+func WithStmtCache(size int) func(*DB) {
+	return func(db *DB) {
+		if size > 0 {
+			db.stmts = newStmtCache(size)
+		}
+	}
+}
+
+// WithCache set the second-level cache of select results
+// enabled per select with attributes cache="true" and ttl="60s".
+func WithCache(cache Cache) func(*DB) {
+	return func(db *DB) {
+		db.cache = cache
+	}
+}
+
+// WithSharedQueries share one database round-trip among the concurrent identical selects out of transaction,
+// each caller receives an independent copy of the result. also enabled per select with attribute shared="true".
+func WithSharedQueries() func(*DB) {
+	return func(db *DB) {
+		db.sharedQueries = true
+	}
+}
+
+// WithDefaultTimeout set the timeout of the statements without timeout attribute,
+// the statement is canceled if the execution and scanning not finished in time.
+func WithDefaultTimeout(timeout time.Duration) func(*DB) {
+	return func(db *DB) {
+		db.defaultTimeout = timeout
+	}
+}
+
+// WithInterceptors add interceptors around the bind, prepare, exec and scan phases of statements,
+// the first one is the outermost.
+func WithInterceptors(interceptors ...Interceptor) func(*DB) {
+	return func(db *DB) {
+		db.interceptors = append(db.interceptors, interceptors...)
+	}
+}
+
+// WithTracer start span around each statement and transaction with tracer
+func WithTracer(tracer Tracer) func(*DB) {
+	return func(db *DB) {
+		db.tracer = tracer
+	}
+}
+
+// WithStats collect the metrics of statements, see DB.Stats
+func WithStats() func(*DB) {
+	return func(db *DB) {
+		db.stats = &statsRegistry{}
+	}
+}
+
+// WithSlowQueryThreshold invoke handler with the statements slower than threshold,
+// the threshold is overridden by the slow attribute of statement or mapper, forexample: <select slow="200ms">.
+// threshold <= 0 means only the statements with slow attribute are detected.
+func WithSlowQueryThreshold(threshold time.Duration, handler SlowQueryHandler) func(*DB) {
+	return func(db *DB) {
+		db.slowThreshold = threshold
+		db.slowHandler = handler
+	}
+}
+
+// WithDebugLogSampling send the fraction rate of the debug logs to logger, forexample: 0.01 for 1%,
+// the info and error logs are always sent.
+func WithDebugLogSampling(rate float64) func(*DB) {
+	return func(db *DB) {
+		db.debugSampling = rate
+	}
+}
+
+// WithExprFunctions add functions callable from the test, collection and placeholder expressions,
+// the variable ctx is the context of statement.
+//
+// forexample:
+//
+//	gobatis.WithExprFunctions(map[string]any{
+//		`isBlank`: func(s string) bool { return strings.TrimSpace(s) == `` },
+//		`hasRole`: func(ctx context.Context, role string) bool { ... },
+//	})
+//
+//	<if test="!isBlank(name) and hasRole(ctx, 'admin')">
+func WithExprFunctions(functions map[string]any) func(*DB) {
+	return func(db *DB) {
+		db.exprEnv = mergeEnv(db.exprEnv, functions)
+	}
+}
+
+// WithExprConstants add constants visible to the mapper expressions, the Args take precedence.
+func WithExprConstants(constants map[string]any) func(*DB) {
+	return func(db *DB) {
+		db.exprEnv = mergeEnv(db.exprEnv, constants)
+	}
+}
+
+// WithContextArgs set fn deriving variables from the context of statements, such as tenant id or current user,
+// the variables are bound to _ctx of every statement, the Args take precedence.
+//
+// forexample:
+//
+//	gobatis.WithContextArgs(func(ctx context.Context) gobatis.Args {
+//		return gobatis.Args{`tenant_id`: tenantOf(ctx)}
+//	})
+//
+//	select * from orders where tenant_id = #{_ctx.tenant_id}
+func WithContextArgs(fn func(ctx context.Context) Args) func(*DB) {
+	return func(db *DB) {
+		db.contextArgs = fn
+	}
+}
+
+// renderEnv return the expression env of the statement with the context variables bound
+func (b *DB) renderEnv() map[string]any {
+	if b.contextArgs == nil {
+		return b.exprEnv
+	}
+	args := b.contextArgs(b.ctx)
+	if args == nil {
+		return b.exprEnv
+	}
+	return mergeEnv(b.exprEnv, map[string]any{contextArgsVariable: args})
+}
+
+// mergeEnv return a copy of env with values added
+func mergeEnv(env, values map[string]any) map[string]any {
+	merged := make(map[string]any, len(env)+len(values))
+	for k, v := range env {
+		merged[k] = v
+	}
+	for k, v := range values {
+		merged[k] = v
+	}
+	return merged
+}
+
 // WithMapper set mapper from outspace
 func WithMapper(mapper *Mapper) func(*DB) {
 	return func(db *DB) {
@@ -141,8 +363,10 @@ func WithMapper(mapper *Mapper) func(*DB) {
 		db.mapUpdate(mapper)
 		db.mapDelete(mapper)
 		for _, sqlMapper := range mapper.Sql {
-			db.sqlMapper[sqlMapper.Id] = sqlMapper.Text
+			db.sqlMapper[sqlMapper.Id] = sqlMapper
 		}
+		// the statements of the mappers replaced are not used any more
+		db.ResetStmtCache()
 	}
 }
 
@@ -153,23 +377,31 @@ func Open(driverName, dataSourceName string, opts ...func(*DB)) (*DB, error) {
 		return nil, err
 	}
 	ret := &DB{
-		db:           db,
-		tx:           nil,
-		selectMapper: make(map[string]*Select, 32),
-		insertMapper: make(map[string]*Insert, 32),
-		updateMapper: make(map[string]*Update, 32),
-		deleteMapper: make(map[string]*Delete, 32),
-		sqlMapper:    make(map[string]string, 32),
-		Error:        nil,
-		mapperType:   0,
-		rows:         nil,
-		ctx:          context.TODO(),
-		driverName:   strings.ToLower(driverName),
-		recordLog:    true,
+		db:            db,
+		tx:            nil,
+		selectMapper:  make(map[string]*Select, 32),
+		insertMapper:  make(map[string]*Insert, 32),
+		updateMapper:  make(map[string]*Update, 32),
+		deleteMapper:  make(map[string]*Delete, 32),
+		sqlMapper:     make(map[string]*Sql, 32),
+		Error:         nil,
+		mapperType:    0,
+		rows:          nil,
+		ctx:           context.TODO(),
+		driverName:    strings.ToLower(driverName),
+		recordLog:     true,
+		flights:       &flightGroup{},
+		debugSampling: 1,
 	}
 	for _, opt := range opts {
 		opt(ret)
 	}
+	if ret.replicas != nil {
+		if err = ret.replicas.open(ret.driverName, ret.stmts); err != nil {
+			_ = db.Close()
+			return nil, err
+		}
+	}
 	return ret, nil
 }
 
@@ -194,22 +426,46 @@ func OpenWithEmbedFs(
 
 func (b *DB) Clone() *DB {
 	return &DB{
-		db:           b.db,
-		tx:           b.tx,
-		selectMapper: b.selectMapper,
-		insertMapper: b.insertMapper,
-		updateMapper: b.updateMapper,
-		deleteMapper: b.deleteMapper,
-		sqlMapper:    b.sqlMapper,
-		mapper:       b.mapper,
-		mapperType:   b.mapperType,
-		Error:        b.Error,
-		rows:         b.rows,
-		ctx:          b.ctx,
-		bindVars:     b.bindVars,
-		logger:       b.logger,
-		recordLog:    b.recordLog,
-		startTime:    b.startTime,
+		db:               b.db,
+		tx:               b.tx,
+		selectMapper:     b.selectMapper,
+		insertMapper:     b.insertMapper,
+		updateMapper:     b.updateMapper,
+		deleteMapper:     b.deleteMapper,
+		sqlMapper:        b.sqlMapper,
+		mapper:           b.mapper,
+		mapperType:       b.mapperType,
+		mapperId:         b.mapperId,
+		Error:            b.Error,
+		rows:             b.rows,
+		rowsEnd:          b.rowsEnd,
+		ctx:              b.ctx,
+		bindVars:         b.bindVars,
+		logger:           b.logger,
+		structuredLogger: b.structuredLogger,
+		driverName:       b.driverName,
+		recordLog:        b.recordLog,
+		strictScan:       b.strictScan,
+		noBeautify:       b.noBeautify,
+		stmts:            b.stmts,
+		cache:            b.cache,
+		sharedQueries:    b.sharedQueries,
+		flights:          b.flights,
+		defaultTimeout:   b.defaultTimeout,
+		deadline:         b.deadline,
+		exprEnv:          b.exprEnv,
+		contextArgs:      b.contextArgs,
+		interceptors:     b.interceptors,
+		tracer:           b.tracer,
+		stats:            b.stats,
+		slowThreshold:    b.slowThreshold,
+		slowHandler:      b.slowHandler,
+		debugSampling:    b.debugSampling,
+		replicas:         b.replicas,
+		usePrimary:       b.usePrimary,
+		txInvalidation:   b.txInvalidation,
+		txStmts:          b.txStmts,
+		startTime:        b.startTime,
 	}
 }
 
@@ -246,6 +502,9 @@ func (b *DB) parseXmlMapper(fs embed.FS, directory string, directories []fs.DirE
 		if _, ok := mappers.AttrMap[TypeKey]; !ok && len(b.driverName) != 0 {
 			mappers.AttrMap[TypeKey] = b.driverName
 		}
+		if _, ok := mappers.AttrMap[NamespaceKey]; !ok {
+			mappers.AttrMap[NamespaceKey] = filePath
+		}
 
 		b.mapSelect(mappers)
 		b.mapInsert(mappers)
@@ -253,13 +512,26 @@ func (b *DB) parseXmlMapper(fs embed.FS, directory string, directories []fs.DirE
 		b.mapDelete(mappers)
 
 		for _, sqlMapper := range mappers.Sql {
-			b.sqlMapper[sqlMapper.Id] = sqlMapper.Text
+			b.sqlMapper[sqlMapper.Id] = sqlMapper
 		}
 	}
 
 	return nil
 }
 
+// inheritedKeys attributes of mapper inherited by the statements not set them
+var inheritedKeys = []string{NamespaceKey, TimeoutKey, SlowKey, MasterKey}
+
+func inheritAttrs(attrs, mapperAttrs map[string]string) {
+	for _, key := range inheritedKeys {
+		if value, ok := mapperAttrs[key]; ok {
+			if _, ok := attrs[key]; !ok {
+				attrs[key] = value
+			}
+		}
+	}
+}
+
 func (b *DB) mapSelect(mappers *Mapper) {
 	for i, selectMapper := range mappers.Select {
 		if mapperTypeValue, ok := mappers.AttrMap[TypeKey]; ok {
@@ -267,6 +539,7 @@ func (b *DB) mapSelect(mappers *Mapper) {
 				selectMapper.AttrsMap[TypeKey] = mapperTypeValue
 			}
 		}
+		inheritAttrs(selectMapper.AttrsMap, mappers.AttrMap)
 		if value, ok := selectMapper.AttrsMap[IdKey]; ok {
 			if _, ok := b.selectMapper[value]; ok {
 				panic(fmt.Errorf("gobatis: select mapper with id: %s redeclared", value))
@@ -283,6 +556,7 @@ func (b *DB) mapInsert(mappers *Mapper) {
 				insertMapper.AttrsMap[TypeKey] = mapperTypeValue
 			}
 		}
+		inheritAttrs(insertMapper.AttrsMap, mappers.AttrMap)
 		if value, ok := insertMapper.AttrsMap[IdKey]; ok {
 			if _, ok := b.insertMapper[value]; ok {
 				panic(fmt.Errorf("gobatis: insert mapper with id: %s redeclared", value))
@@ -299,6 +573,7 @@ func (b *DB) mapUpdate(mappers *Mapper) {
 				updateMapper.AttrsMap[TypeKey] = mapperTypeValue
 			}
 		}
+		inheritAttrs(updateMapper.AttrsMap, mappers.AttrMap)
 		if value, ok := updateMapper.AttrsMap[IdKey]; ok {
 			if _, ok := b.updateMapper[value]; ok {
 				panic(fmt.Errorf("gobatis: update mapper with id: %s redeclared", value))
@@ -315,6 +590,7 @@ func (b *DB) mapDelete(mappers *Mapper) {
 				deleteMapper.AttrsMap[TypeKey] = mapperTypeValue
 			}
 		}
+		inheritAttrs(deleteMapper.AttrsMap, mappers.AttrMap)
 		if value, ok := deleteMapper.AttrsMap[IdKey]; ok {
 			if _, ok := b.deleteMapper[value]; ok {
 				panic(fmt.Errorf("gobatis: delete mapper with id: %s redeclared", value))
@@ -324,6 +600,18 @@ func (b *DB) mapDelete(mappers *Mapper) {
 	}
 }
 
+// Close close the cached prepared statements, the database and the replicas opened from dsns
+func (b *DB) Close() error {
+	b.ResetStmtCache()
+	err := b.db.Close()
+	if b.replicas != nil {
+		if closeErr := b.replicas.close(); err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
+
 // WithContext set context to db
 func (b *DB) WithContext(ctx context.Context) *DB {
 	db := b.Clone()
@@ -342,6 +630,10 @@ func (b *DB) Transaction(fn func(tx *DB) error) (err error) {
 	}
 
 	db := b.Clone()
+	end := db.startSpan(StatementTransaction)
+	defer func() {
+		end(err, 0)
+	}()
 
 	tx, err = db.db.BeginTx(db.ctx, &sql.TxOptions{
 		Isolation: 0,
@@ -353,14 +645,7 @@ func (b *DB) Transaction(fn func(tx *DB) error) (err error) {
 
 	defer func() {
 		if rerr := recover(); rerr != nil {
-			switch x := rerr.(type) {
-			case error:
-				err = x
-			case string:
-				err = errors.New(x)
-			default:
-				err = fmt.Errorf("transaction panic: %v", rerr)
-			}
+			err = panicError(rerr)
 			if rollbackErr := tx.Rollback(); rollbackErr != nil {
 				err = fmt.Errorf(`rollback error: %#v, raw error: %#v`, rollbackErr, err)
 			}
@@ -372,92 +657,296 @@ func (b *DB) Transaction(fn func(tx *DB) error) (err error) {
 			}
 		} else if err = tx.Commit(); err == nil {
 			db.tx = nil
+			if db.replicas != nil {
+				// the writes are visible to replicas after committed
+				db.replicas.written(db.ctx, true)
+			}
+			if db.cache != nil {
+				db.txInvalidation.flush(db.cache)
+			}
 		}
 	}()
 
 	db.tx = tx
+	db.txInvalidation = &txInvalidation{namespaces: make(map[string]bool)}
+	db.txStmts = &txStmts{stmts: make(map[string]*sql.Stmt)}
 
 	return fn(db)
 }
 
+// Savepoint run fn in a savepoint of the current transaction, the changes of fn are rolled back
+// to the savepoint if it failed or panicked, and the transaction goes on.
+// it's the same as Transaction if not in transaction.
+//
+// forexample:
+//
+//	db.Transaction(func(tx *gobatis.DB) error {
+//		...
+//		err := tx.Savepoint(func(sp *gobatis.DB) error { ... })
+//	})
+func (b *DB) Savepoint(fn func(tx *DB) error) (err error) {
+	if b.tx == nil {
+		return b.Transaction(fn)
+	}
+	if b.Error != nil {
+		return b.Error
+	}
+
+	db := b.Clone()
+	end := db.startSpan(StatementTransaction)
+	defer func() {
+		end(err, 0)
+	}()
+
+	dialect := db.Dialect()
+	name := savepointName()
+	if _, err = db.tx.ExecContext(db.ctx, dialect.Savepoint(name)); err != nil {
+		return err
+	}
+
+	defer func() {
+		if rerr := recover(); rerr != nil {
+			err = panicError(rerr)
+		}
+
+		if err != nil {
+			if _, rollbackErr := db.tx.ExecContext(db.ctx, dialect.RollbackToSavepoint(name)); rollbackErr != nil {
+				err = fmt.Errorf(`rollback error: %#v, raw error: %#v`, rollbackErr, err)
+			}
+		} else if release := dialect.ReleaseSavepoint(name); release != `` {
+			_, err = db.tx.ExecContext(db.ctx, release)
+		}
+	}()
+
+	return fn(db)
+}
+
+// savepointSeq sequence of the savepoint names
+var savepointSeq uint64
+
+// savepointName generate a unique savepoint name, gobatis_sp<N> is 30 bytes at most,
+// within the identifier limit of oracle before 12.2 and sqlserver.
+func savepointName() string {
+	return `gobatis_sp` + strconv.FormatUint(atomic.AddUint64(&savepointSeq, 1), 10)
+}
+
+// panicError convert the recovered value of panic to error
+func panicError(rerr any) error {
+	switch x := rerr.(type) {
+	case error:
+		return x
+	case string:
+		return errors.New(x)
+	default:
+		return fmt.Errorf("transaction panic: %v", rerr)
+	}
+}
+
 // RawQuery database
 // then call Find to get result
 func (b *DB) RawQuery(query string, args ...any) *DB {
+	args, masks := unwrapSecrets(args)
 	db := b.Clone()
-
-	db.startTime = time.Now()
-	db.bindVars = &BindVar{
+	bindVars, err := db.prepare(&BindVar{
 		stateSql: query,
 		args:     args,
+		masks:    masks,
+		typ:      b.driverName,
 		err:      nil,
+	})
+	if err != nil {
+		db.Error = err
+		return db
 	}
+	return db.openRows(StatementRaw, bindVars)
+}
 
-	if db.tx != nil {
-		db.rows, db.Error = db.tx.QueryContext(db.ctx, query, args...)
-	} else {
-		db.rows, db.Error = db.db.QueryContext(db.ctx, query, args...)
+// openRows query the prepared statements and keep the rows for Find,
+// the span and the deadline of the statement are ended after the rows closed.
+func (db *DB) openRows(typ string, bindVars *BindVar) *DB {
+	db.startTime = time.Now()
+	db.bindVars = bindVars
+
+	// the deadline is derived from the caller's context, and the span is nested in it
+	if err := db.withDeadline(); err != nil {
+		db.Error = db.wrapError(PhaseBind, err)
+		return db
 	}
+	end := db.startSpan(typ)
+	if db.runQuery(bindVars); db.Error != nil {
+		end(db.Error, 0)
+		db.releaseDeadline()
+		return db
+	}
+	db.rowsEnd = end
+	return db
+}
+
+// query run the prepared statements on the cloned db
+func (db *DB) query(bindVars *BindVar) *DB {
+	db.startTime = time.Now()
+	db.bindVars = bindVars
 
+	// the deadline is released after the rows closed
+	if err := db.withDeadline(); err != nil {
+		db.Error = db.wrapError(PhaseBind, err)
+		return db
+	}
+	if db.runQuery(bindVars); db.Error != nil {
+		db.releaseDeadline()
+	}
+	return db
+}
+
+// runQuery run the prepared statements within the deadline derived
+func (db *DB) runQuery(bindVars *BindVar) *DB {
+	// the selects outside transaction go to replica if any
+	pool, stmts := db.db, db.stmts
+	if replica := db.replica(); replica != nil {
+		pool, stmts = replica.db, replica.stmts
+	}
+
+	db.Error = db.intercept(PhaseExec, db.invocation(bindVars), func() (err error) {
+		if stmts != nil {
+			return db.withStmt(pool, stmts, bindVars.stateSql, func(stmt *sql.Stmt) (err error) {
+				db.rows, err = stmt.QueryContext(db.ctx, bindVars.args...)
+				return err
+			})
+		} else if db.tx != nil {
+			db.rows, err = db.tx.QueryContext(db.ctx, bindVars.stateSql, bindVars.args...)
+		} else {
+			db.rows, err = pool.QueryContext(db.ctx, bindVars.stateSql, bindVars.args...)
+		}
+		return err
+	})
+	db.Error = db.wrapError(PhaseExec, db.Error)
 	return db
 }
 
 // RawExec do an insert, update or delete operation
 func (b *DB) RawExec(query string, args ...any) *DB {
-	var err error
-	var result sql.Result
 	if b.Error != nil {
-		b.logger.Log(b.ctx, LogLevelError, time.Now().Sub(b.startTime).Nanoseconds(), ``, b.Error)
+		b.log(LogLevelError, b.Error, ``)
 		return b
 	}
 
+	args, masks := unwrapSecrets(args)
 	db := b.Clone()
-	db.startTime = time.Now()
-	db.bindVars = &BindVar{
+	bindVars, err := db.prepare(&BindVar{
 		stateSql: query,
 		args:     args,
+		masks:    masks,
+		typ:      b.driverName,
 		err:      nil,
-	}
-
-	if db.tx != nil {
-		result, err = db.tx.ExecContext(db.ctx, query, args...)
-	} else {
-		result, err = db.db.ExecContext(db.ctx, query, args...)
-	}
+	})
 	if err != nil {
 		db.Error = err
 		return db
 	}
+	db.bindVars = bindVars
+	end := db.startSpan(StatementRaw)
+	defer func() {
+		db.done(end, db.RowsAffected)
+	}()
+	return db.exec(bindVars)
+}
+
+// exec run the prepared statements on the cloned db
+func (db *DB) exec(bindVars *BindVar) *DB {
+	var err error
+
+	db.startTime = time.Now()
+	db.bindVars = bindVars
+
+	if err = db.withDeadline(); err != nil {
+		db.Error = db.wrapError(PhaseBind, err)
+		return db
+	}
+	defer db.releaseDeadline()
+
+	inv := db.invocation(bindVars)
+	err = db.intercept(PhaseExec, inv, func() (err error) {
+		var result sql.Result
+		if db.stmts != nil {
+			err = db.withStmt(db.db, db.stmts, bindVars.stateSql, func(stmt *sql.Stmt) (err error) {
+				result, err = stmt.ExecContext(db.ctx, bindVars.args...)
+				return err
+			})
+		} else if db.tx != nil {
+			result, err = db.tx.ExecContext(db.ctx, bindVars.stateSql, bindVars.args...)
+		} else {
+			result, err = db.db.ExecContext(db.ctx, bindVars.stateSql, bindVars.args...)
+		}
+		if err != nil {
+			return err
+		}
 
-	db.RowsAffected, db.Error = result.RowsAffected()
-	if db.Error != nil {
+		if inv.RowsAffected, err = result.RowsAffected(); err != nil {
+			return err
+		}
+		inv.LastInsertId, _ = result.LastInsertId()
+		return nil
+	})
+	if err != nil {
+		db.Error = db.wrapError(PhaseExec, err)
 		return db
 	}
 
-	db.LastInserId, _ = result.LastInsertId()
+	db.RowsAffected, db.LastInserId = inv.RowsAffected, inv.LastInsertId
+	db.invalidateCache()
+	db.written()
 	return db
 }
 
+// done finish the span, metrics and slow query detection of the statement
+func (db *DB) done(end SpanEnd, rows int64) {
+	end(db.Error, rows)
+	db.observe(db.Error, rows)
+	db.detectSlow()
+}
+
 // Find  result from previous Query call
 func (b *DB) Find(dest any) *DB {
 	if b.Error != nil {
-		b.logger.Log(b.ctx, LogLevelError, time.Now().Sub(b.startTime).Nanoseconds(), ``,b.Error)
+		b.log(LogLevelError, b.Error, ``)
 		return b
 	}
 
 	db := b.Clone()
 	statements, args, err := db.bindVars.Vars()
 	if err != nil {
-		db.Error = err
+		db.Error = db.wrapError(PhaseBind, err)
 		return db
 	}
 	db.recordLog = true
+	// the rows of RawQuery and Bind are timed from querying, and traced by their span
+	end := func(error, int64) {}
+	if db.rows == nil {
+		db.startTime = time.Now()
+		end = db.startSpan(db.statementType())
+	}
+	before := resultLen(dest)
+	// rows returned
+	rows := func() int64 {
+		if n := resultLen(dest); n >= 0 {
+			return int64(n - before)
+		} else if db.Error == nil {
+			return 1
+		}
+		return 0
+	}
+	defer func() {
+		db.done(end, rows())
+	}()
 	defer func() {
-		if db.logger != nil && db.recordLog {
+		if db.recordLog {
 			logLevel := LogLevelDebug
 			if db.Error != nil {
 				logLevel = LogLevelError
 			}
-			db.logger.Log(db.ctx, logLevel, time.Now().Sub(db.startTime).Nanoseconds(), statements, args...)
+			event := db.newLogEvent(logLevel, db.Error, statements, maskArgs(args, db.bindVars.masks))
+			event.Rows = rows()
+			db.emit(event)
 		}
 	}()
 
@@ -465,26 +954,105 @@ func (b *DB) Find(dest any) *DB {
 	// to support postgres-like sql: insert/update/delete xxx returning xxx
 	case mapperInsert, mapperUpdate, mapperDelete:
 		db.recordLog = false
-		db = db.RawQuery(statements, args...)
+		db = db.query(db.bindVars)
 		db.recordLog = true
+		if db.Error != nil {
+			return db
+		}
+		db.invalidateCache()
+		db.written()
+	case mapperSelect:
+		// queried by RawQuery if rows not nil
+		if db.rows == nil {
+			db.Error = db.findSelect(dest)
+			return db
+		}
 	default:
 		// omit
 	}
 
 	if db.rows == nil {
-		db.Error = ErrorNowRowsFound
+		db.Error = db.wrapError(PhaseExec, ErrorNowRowsFound)
 		return db
 	}
 	defer func() {
-		db.rows.Close()
-		db.rows = nil
+		db.closeRows(rows())
 	}()
 
-	db.Error = db.scan(db.rows, dest)
+	db.Error = db.wrapError(PhaseScan, db.scanRows(dest))
 
 	return db
 }
 
+// closeRows close the rows, end the span of rows with the rows scanned, and release the deadline of the statement
+func (db *DB) closeRows(rows int64) {
+	db.rows.Close()
+	db.rows = nil
+	if db.rowsEnd != nil {
+		db.rowsEnd(db.Error, rows)
+		db.rowsEnd = nil
+	}
+	db.releaseDeadline()
+}
+
+// findSelect query the select statement and scan the result into dest,
+// the result is cached and shared with the concurrent identical queries if enabled.
+func (db *DB) findSelect(dest any) error {
+	ttl, cacheable, err := db.cacheTTL()
+	if err != nil {
+		return db.wrapError(PhaseBind, err)
+	}
+	shared := db.isShared()
+	if rv := reflect.ValueOf(dest); rv.Kind() != reflect.Ptr || rv.IsNil() || db.forcedPrimary() {
+		cacheable, shared = false, false
+	}
+
+	var namespace, key string
+	if cacheable {
+		namespace, _ = db.mapperAttr(NamespaceKey)
+		// the uncommitted writes of transaction are invisible to cache
+		cacheable = db.tx == nil || db.txInvalidation == nil || !db.txInvalidation.written(namespace)
+	}
+	if cacheable || shared {
+		var ok bool
+		if key, ok = db.cacheKey(dest); !ok {
+			cacheable, shared = false, false
+		}
+	}
+	if cacheable && db.loadCache(namespace, key, dest) {
+		return nil
+	}
+
+	// the cached result is scanned into a fresh value, the elements already in dest are not cached
+	target := dest
+	var result reflect.Value
+	if cacheable {
+		result = reflect.New(reflect.TypeOf(dest).Elem())
+		target = result.Interface()
+	}
+
+	if shared {
+		err = db.wrapError(PhaseExec, db.flights.do(db.ctx, key, target, db.queryScan))
+	} else {
+		err = db.queryScan(target)
+	}
+	if err != nil || !cacheable {
+		return err
+	}
+	db.cache.Set(namespace, key, cloneValue(result.Elem()).Interface(), ttl)
+	mergeResult(reflect.ValueOf(dest).Elem(), result.Elem())
+	return nil
+}
+
+// queryScan query the statement and scan the result into dest
+func (db *DB) queryScan(dest any) error {
+	if db.query(db.bindVars); db.Error != nil {
+		return db.Error
+	}
+	defer db.closeRows(0)
+	return db.wrapError(PhaseScan, db.scanRows(dest))
+}
+
 // Mapper fetch mapper from all xml with the id identifier
 //
 // forexample:
@@ -510,6 +1078,7 @@ func (b *DB) Mapper(mapperId string) *DB {
 	db := b.Clone()
 
 	db.startTime = time.Now()
+	db.mapperId = mapperId
 
 	if mapper, ok := db.selectMapper[mapperId]; ok {
 		db.mapper = mapper
@@ -535,7 +1104,7 @@ func (b *DB) Mapper(mapperId string) *DB {
 		return db
 	}
 
-	db.Error = fmt.Errorf("gobatis: mapper with id: %s not found", mapperId)
+	db.Error = db.wrapError(PhaseBind, fmt.Errorf("gobatis: mapper with id: %s not found", mapperId))
 	return db
 }
 
@@ -546,6 +1115,7 @@ func (b *DB) SelectMapper(mapperId string) *DB {
 	}
 	db := b.Clone()
 	db.startTime = time.Now()
+	db.mapperId = mapperId
 
 	if mapper, ok := db.selectMapper[mapperId]; ok {
 		db.mapper = mapper
@@ -553,7 +1123,7 @@ func (b *DB) SelectMapper(mapperId string) *DB {
 		return db
 	}
 
-	db.Error = fmt.Errorf("gobatis: mapper with id: %s not found", mapperId)
+	db.Error = db.wrapError(PhaseBind, fmt.Errorf("gobatis: mapper with id: %s not found", mapperId))
 	return db
 }
 
@@ -564,6 +1134,7 @@ func (b *DB) InsertMapper(mapperId string) *DB {
 	}
 	db := b.Clone()
 	db.startTime = time.Now()
+	db.mapperId = mapperId
 
 	if mapper, ok := db.insertMapper[mapperId]; ok {
 		db.mapper = mapper
@@ -571,7 +1142,7 @@ func (b *DB) InsertMapper(mapperId string) *DB {
 		return db
 	}
 
-	db.Error = fmt.Errorf("gobatis: mapper with id: %s not found", mapperId)
+	db.Error = db.wrapError(PhaseBind, fmt.Errorf("gobatis: mapper with id: %s not found", mapperId))
 	return db
 }
 
@@ -582,6 +1153,7 @@ func (b *DB) UpdateMapper(mapperId string) *DB {
 	}
 	db := b.Clone()
 	db.startTime = time.Now()
+	db.mapperId = mapperId
 
 	if mapper, ok := db.updateMapper[mapperId]; ok {
 		db.mapper = mapper
@@ -589,7 +1161,7 @@ func (b *DB) UpdateMapper(mapperId string) *DB {
 		return db
 	}
 
-	db.Error = fmt.Errorf("gobatis: mapper with id: %s not found", mapperId)
+	db.Error = db.wrapError(PhaseBind, fmt.Errorf("gobatis: mapper with id: %s not found", mapperId))
 	return db
 }
 
@@ -600,6 +1172,7 @@ func (b *DB) DeleteMapper(mapperId string) *DB {
 	}
 	db := b.Clone()
 	db.startTime = time.Now()
+	db.mapperId = mapperId
 
 	if mapper, ok := db.deleteMapper[mapperId]; ok {
 		db.mapper = mapper
@@ -607,13 +1180,42 @@ func (b *DB) DeleteMapper(mapperId string) *DB {
 		return db
 	}
 
-	db.Error = fmt.Errorf("gobatis: mapper with id: %s not found", mapperId)
+	db.Error = db.wrapError(PhaseBind, fmt.Errorf("gobatis: mapper with id: %s not found", mapperId))
 	return db
 }
 
-// Args alias to Bind operation
+// mapperAttr fetch attribute of current mapper
+func (b *DB) mapperAttr(key string) (string, bool) {
+	var attrs map[string]string
+	switch m := b.mapper.(type) {
+	case *Select:
+		attrs = m.AttrsMap
+	case *Insert:
+		attrs = m.AttrsMap
+	case *Update:
+		attrs = m.AttrsMap
+	case *Delete:
+		attrs = m.AttrsMap
+	default:
+		return ``, false
+	}
+	value, ok := attrs[key]
+	return value, ok
+}
+
+// isStrictScan report whether scan in strict mode
+func (b *DB) isStrictScan() bool {
+	if b.strictScan {
+		return true
+	}
+	value, _ := b.mapperAttr(StrictKey)
+	return strings.EqualFold(value, `true`)
+}
+
+// Args bind variables to mapper like Bind, but the select statements are executed by Find,
+// so the cached results and the shared queries are used.
 func (b *DB) Args(variables interface{}) *DB {
-	return b.Bind(variables)
+	return b.bind(variables)
 }
 
 var (
@@ -626,17 +1228,62 @@ var (
 // next call will use the stmt.
 // caller should have known if the variables input was map, he must make sure the input variables
 // [ thread-safe ].
+// the select statements are executed immediately, and the rows are scanned by Find,
+// use Args for the second-level cache and the shared queries.
 func (b *DB) Bind(variables interface{}) *DB {
+	db := b.bind(variables)
+	if db.Error != nil || db.mapperType != mapperSelect {
+		return db
+	}
+	return db.openRows(StatementSelect, db.bindVars)
+}
+
+// bind variables to mapper and render the prepared statement
+func (b *DB) bind(variables interface{}) *DB {
 	if b.Error != nil {
 		return b
 	}
 
 	db := b.Clone()
 	if db.mapper == nil {
-		db.Error = ErrorMapperCallFirst
+		db.Error = db.wrapError(PhaseBind, ErrorMapperCallFirst)
 		return db
 	}
 
+	bindVars, err := db.render(variables)
+	if err != nil {
+		db.bindVars = nil
+		db.Error = db.wrapError(PhaseBind, err)
+		return db
+	}
+	db.bindVars = bindVars
+	return db
+}
+
+// render bind variables to mapper and generate the prepared statement, through the bind and prepare interceptors
+func (b *DB) render(variables interface{}) (*BindVar, error) {
+	if b.stats != nil {
+		defer b.observeRender(time.Now())
+	}
+	if len(b.interceptors) == 0 {
+		return b.renderInput(variables)
+	}
+
+	var bindVars *BindVar
+	inv := b.invocation(nil)
+	inv.Input = variables
+	err := b.intercept(PhaseBind, inv, func() (err error) {
+		bindVars, err = b.renderInput(inv.Input)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return b.prepare(bindVars)
+}
+
+// renderInput render the mapper with variables
+func (b *DB) renderInput(variables interface{}) (*BindVar, error) {
 	t := reflect.TypeOf(variables)
 	for t.Kind() == reflect.Ptr {
 		t = t.Elem()
@@ -645,75 +1292,90 @@ func (b *DB) Bind(variables interface{}) *DB {
 	switch t.Kind() {
 	case reflect.Map, reflect.Struct:
 	default:
-		db.Error = ErrorBindArgsNeedBeMapOrStruct
-		return db
+		return nil, ErrorBindArgsNeedBeMapOrStruct
 	}
 
-	// make sure the input value's type is map
-	// if tag contains foreach, the input' type must be `map`
-	// caller should have known if the variables input was map, he must make sure the input variables
-	// thread-safe.
+	// collect names of the fields tagged with gobatis:"sensitive"
+	var sensitive map[string]bool
 	if t.Kind() == reflect.Struct {
-		var variablesMap = make(map[string]interface{}, 32)
-		value := reflect.ValueOf(variables)
-		for value.Kind() == reflect.Ptr {
-			value = value.Elem()
-		}
-		for i := 0; i < t.NumField(); i++ {
-			field := t.Field(i)
-			fieldValue := value.Field(i)
-
-			if field.Anonymous {
-			dealAnonymous:
-				// deal with anonymous field
-				anonymousFieldType := field.Type
-				for anonymousFieldType.Kind() == reflect.Ptr {
-					anonymousFieldType = anonymousFieldType.Elem()
-				}
-				anonymousFieldValue := fieldValue
-				for anonymousFieldValue.Kind() == reflect.Ptr {
-					anonymousFieldValue = anonymousFieldValue.Elem()
-				}
-				for j := 0; j < anonymousFieldType.NumField(); j++ {
-					if anonymousFieldType.Field(j).Anonymous {
-						field = anonymousFieldType.Field(j)
-						fieldValue = anonymousFieldValue.Field(j)
-						// embed field goto next for loop
-						goto dealAnonymous
-					}
-					field := anonymousFieldType.Field(j)
-					fieldValue := anonymousFieldValue.Field(j)
-					variablesMap[b.columnName(field)] = fieldValue.Interface()
-					variablesMap[field.Name] = fieldValue.Interface()
+		sensitive = typeInfoOf(t).sensitive
+	} else {
+		iter := reflect.Indirect(reflect.ValueOf(variables)).MapRange()
+		for iter.Next() {
+			value := iter.Value()
+			if value.Kind() == reflect.Interface {
+				if value.IsNil() {
+					continue
 				}
+				value = value.Elem()
+			}
+			names := sensitiveOf(value.Type())
+			if len(names) == 0 {
 				continue
 			}
-			variablesMap[b.columnName(field)] = fieldValue.Interface()
-			variablesMap[field.Name] = fieldValue.Interface()
+			if sensitive == nil {
+				sensitive = make(map[string]bool, len(names))
+			}
+			for name := range names {
+				sensitive[name] = true
+			}
 		}
-		variables = variablesMap
 	}
 
-	db.bindVars = db.mapper.Bind(db.ctx, &HandlerPayload{
-		Input: variables, SqlMapper: db.sqlMapper, fromChoose: false, uuidMap: sync.Map{},
+	// variables are copied into the scope of rendering, they're never modified,
+	// so the same variables can be shared across goroutines.
+	bindVars := b.mapper.Bind(b.ctx, &HandlerPayload{
+		Input: variables, SqlMapper: b.sqlMapper, fromChoose: false, sensitive: sensitive,
+		noBeautify: b.noBeautify, env: b.renderEnv(),
 	})
-	statements, args, err := db.bindVars.Vars()
+	statements, _, err := bindVars.Vars()
 	if err != nil {
-		db.Error = err
-		return db
+		return nil, err
 	}
 	if statements == `` {
-		db.Error = ErrorPreparedStatementsEmpty
-		return db
+		return nil, ErrorPreparedStatementsEmpty
 	}
 
-	db.recordLog = false
-	switch db.mapperType {
-	case mapperSelect:
-		return db.RawQuery(statements, args...)
-	default:
-		return db
+	return bindVars, nil
+}
+
+// Render bind variables to mapper and return the prepared statement with it's args
+// nothing will be executed, it's useful for tests and debugging.
+//
+// forexample:
+//
+// statements, args, err := db.Mapper('id').Render(variables)
+func (b *DB) Render(variables interface{}) (string, []interface{}, error) {
+	if b.Error != nil {
+		return ``, nil, b.Error
+	}
+	if b.mapper == nil {
+		return ``, nil, ErrorMapperCallFirst
 	}
+
+	bindVars, err := b.render(variables)
+	if err != nil {
+		return ``, nil, err
+	}
+	return bindVars.Vars()
+}
+
+// InterpolatedSQL bind variables to mapper and return the statement with args inlined as literals
+// of the mapper's database type, which can be copy-pasted into psql or mysql clients.
+// the result is used for debugging only, never execute it.
+func (b *DB) InterpolatedSQL(variables interface{}) (string, error) {
+	if b.Error != nil {
+		return ``, b.Error
+	}
+	if b.mapper == nil {
+		return ``, ErrorMapperCallFirst
+	}
+
+	bindVars, err := b.render(variables)
+	if err != nil {
+		return ``, err
+	}
+	return Interpolate(bindVars.typ, bindVars.stateSql, maskArgs(bindVars.args, bindVars.masks)...)
 }
 
 // Execute database's insert, update and delete
@@ -726,31 +1388,35 @@ func (b *DB) Execute() *DB {
 	db := b.Clone()
 	statements, args, err := db.bindVars.Vars()
 	if err != nil {
-		db.Error = err
+		db.Error = db.wrapError(PhaseBind, err)
 		return db
 	}
+	end := db.startSpan(db.statementType())
 	defer func() {
-		if db.logger != nil && db.recordLog {
+		db.done(end, db.RowsAffected)
+	}()
+	defer func() {
+		if db.recordLog {
 			logLevel := LogLevelDebug
 			if db.Error != nil {
 				logLevel = LogLevelError
 			}
-			db.logger.Log(db.ctx, logLevel, time.Now().Sub(db.startTime).Nanoseconds(), statements, args...)
+			db.log(logLevel, db.Error, statements, maskArgs(args, db.bindVars.masks)...)
 		}
 	}()
 
 	db.recordLog = true
 	switch db.mapperType {
 	case mapperInsert, mapperUpdate, mapperDelete:
-		return db.RawExec(statements, args...)
+		return db.exec(db.bindVars)
 	default:
 		return db
 	}
 }
 
-// columnName fetch name from struct field
+// fieldColumn fetch name from struct field
 // first from struct tag list found, if not, use it's field name to lower form.
-func (b *DB) columnName(f reflect.StructField) string {
+func fieldColumn(f reflect.StructField) string {
 	for _, tag := range tagList {
 		if n, ok := f.Tag.Lookup(tag); ok {
 			for _, piece := range strings.Split(n, `;`) {
@@ -770,120 +1436,168 @@ func (b *DB) columnName(f reflect.StructField) string {
 	return strings.ToLower(f.Name)
 }
 
-func (b *DB) parseEmbed(v map[string][]int, typ reflect.Type, idxs []int, index int) map[string][]int {
-	idx := make([]int, index+1, index+2)
-	copy(idx, idxs)
-
-	for i := 0; i < typ.NumField(); i++ {
-		f := typ.Field(i)
-
-		if f.PkgPath != `` {
-			continue
+// fieldOptional report whether the struct field is tagged with omitempty or ignored by `-`
+// such fields are not required to match a column in strict scan mode.
+func fieldOptional(f reflect.StructField) bool {
+	for _, tag := range tagList {
+		if n, ok := f.Tag.Lookup(tag); ok {
+			return n == `-` || strings.Contains(n, `,omitempty`)
 		}
+	}
+	return false
+}
 
-		idx[index] = i
+// scanColumnName strip the function call and table alias of the column name
+func scanColumnName(column string) string {
+	column = strings.Split(column, "(")[0]
+	return column[strings.Index(column, `.`)+1:]
+}
 
-		if typ := f.Type; f.Anonymous {
-			switch {
-			case typ.Kind() == reflect.Struct:
-				v = b.parseEmbed(v, typ, idx, index+1)
-			}
-			continue
-		}
+type rowScan struct {
+	types []reflect.Type
+	// scan the current row into elem, elem is the addressable element of dest slice
+	scan func(rows *sql.Rows, elem reflect.Value) error
+
+	// field name of each column used for error report, empty if not mapped
+	fields []string
+}
 
-		newIdx := append([]int{}, idx...)
-		v[f.Name] = newIdx
-		v[b.columnName(f)] = newIdx
+// nullTypeOf return the sql.Null type of the column scan type, used for the columns not mapped to fields
+func nullTypeOf(ctype *sql.ColumnType) reflect.Type {
+	switch ctype.ScanType() {
+	case timeType, timePtrType:
+		return nullTimeType
+	case stringType:
+		return nullStringType
+	case boolType:
+		return nullBoolType
+	case int64Type:
+		return nullInt64Type
+	case byteType:
+		return nullByteType
+	case float64Type:
+		return nullFloat64Type
+	case int16Type:
+		return nullInt16Type
+	case int32Type:
+		return nullInt32Type
+	default:
+		return ctype.ScanType()
 	}
-	return v
 }
 
-type rowScan struct {
-	types []reflect.Type
-	ctype []*sql.ColumnType
-	value func(v ...any) (reflect.Value, error)
+var scannerType = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+
+// nullable report whether NULL can be scanned into the type
+func nullable(typ reflect.Type) bool {
+	switch typ.Kind() {
+	case reflect.Ptr, reflect.Interface, reflect.Slice, reflect.Map:
+		return true
+	}
+	return reflect.PtrTo(typ).Implements(scannerType)
 }
 
-func (rs *rowScan) values() []any {
-	vs := make([]any, 0, len(rs.types))
-	for _, typ := range rs.types {
-		vs = append(vs, reflect.New(typ).Interface())
+// nullError rescan the current row to report which column is NULL but mapped to a non-nullable field
+// instead of the driver's generic conversion error, return nil if no such column.
+func (rs *rowScan) nullError(rows *sql.Rows, columns []string) error {
+	if len(rs.fields) == 0 {
+		return nil
 	}
-	return vs
+	holders := make([]any, len(columns))
+	for i := range holders {
+		holders[i] = new(any)
+	}
+	if err := rows.Scan(holders...); err != nil {
+		return nil
+	}
+	for i, holder := range holders {
+		if *(holder.(*any)) != nil || rs.fields[i] == `` || nullable(rs.types[i]) {
+			continue
+		}
+		return fmt.Errorf(
+			"gobatis: column %q is NULL but field %s (%s) is not nullable, use pointer or sql.Null type instead",
+			columns[i], rs.fields[i], rs.types[i],
+		)
+	}
+	return nil
 }
 
 func (b *DB) scanStruct(typ reflect.Type, columns []string, ctypes []*sql.ColumnType) (*rowScan, error) {
-	names := make(map[string][]int, typ.NumField())
-	rs := &rowScan{types: make([]reflect.Type, 0, typ.NumField())}
+	info := typeInfoOf(typ)
+	rs := &rowScan{
+		types:  make([]reflect.Type, 0, len(columns)),
+		fields: make([]string, len(columns)),
+	}
+	matched := make([]*fieldInfo, len(columns))
 
-	names = b.parseEmbed(names, typ, []int{}, 0)
 	for i, column := range columns {
-		var idx []int
-		switch name := strings.Split(column, "(")[0]; {
-		case names[name] != nil:
-			idx = names[name]
-		case names[strings.ToLower(name)] != nil:
-			idx = names[strings.ToLower(name)]
+		var field *fieldInfo
+		switch name := scanColumnName(column); {
+		case info.names[name] != nil:
+			field = info.names[name]
+		case info.names[strings.ToLower(name)] != nil:
+			field = info.names[strings.ToLower(name)]
 		default:
-			switch ctypes[i].ScanType() {
-			case timeType, timePtrType:
-				rs.types = append(rs.types, nullTimeType)
-			case stringType:
-				rs.types = append(rs.types, nullStringType)
-			case boolType:
-				rs.types = append(rs.types, nullBoolType)
-			case int64Type:
-				rs.types = append(rs.types, nullInt64Type)
-			case byteType:
-				rs.types = append(rs.types, nullByteType)
-			case float64Type:
-				rs.types = append(rs.types, nullFloat64Type)
-			case int16Type:
-				rs.types = append(rs.types, nullInt16Type)
-			case int32Type:
-				rs.types = append(rs.types, nullInt32Type)
-			default:
-				rs.types = append(rs.types, ctypes[i].ScanType())
-			}
+			rs.types = append(rs.types, nullTypeOf(ctypes[i]))
 			continue
 		}
-		rtype := typ.Field(idx[0]).Type
-		for _, vi := range idx[1:] {
-			rtype = rtype.Field(vi).Type
-		}
-
-		rs.types = append(rs.types, rtype)
+		matched[i] = field
+		rs.fields[i] = typ.Name() + `.` + field.name
+		rs.types = append(rs.types, field.typ)
 	}
 
-	rs.value = func(vs ...any) (reflect.Value, error) {
-		dest := reflect.New(typ).Elem()
-		for i, v := range vs {
+	if b.isStrictScan() {
+		if err := b.strictScanCheck(typ, columns, matched, info); err != nil {
+			return nil, err
+		}
+	}
 
-			name := columns[i]
-			if reflect.ValueOf(v).IsNil() {
+	// the fields are scanned into directly, the unmapped columns share one discarded holder,
+	// dests is reused across rows.
+	discard := new(any)
+	dests := make([]any, len(columns))
+	rs.scan = func(rows *sql.Rows, elem reflect.Value) error {
+		for i, field := range matched {
+			if field == nil {
+				dests[i] = discard
 				continue
 			}
-			name = name[strings.Index(name, `.`)+1:]
-
-			rv := reflect.Indirect(reflect.ValueOf(v))
+			dests[i] = elem.FieldByIndex(field.index).Addr().Interface()
+		}
+		return rows.Scan(dests...)
+	}
 
-			idx, ok := names[name]
-			if !ok {
-				continue
-			}
+	return rs, nil
+}
 
-			dv := dest.Field(idx[0])
-			for _, vi := range idx[1:] {
-				dv = dv.Field(vi)
-			}
+// strictScanCheck report unmapped columns and unfilled required fields
+func (b *DB) strictScanCheck(typ reflect.Type, columns []string, matched []*fieldInfo, info *typeInfo) error {
+	var unmapped, unfilled []string
 
-			dv.Set(rv)
+	filled := make(map[*fieldInfo]bool, len(columns))
+	for i, field := range matched {
+		if field == nil {
+			unmapped = append(unmapped, columns[i])
+			continue
 		}
+		filled[field] = true
+	}
 
-		return dest, nil
+	for _, field := range info.fields {
+		if field.viaPointer || filled[field] || field.optional {
+			continue
+		}
+		unfilled = append(unfilled, field.name)
 	}
+	sort.Strings(unfilled)
 
-	return rs, nil
+	if len(unmapped) == 0 && len(unfilled) == 0 {
+		return nil
+	}
+	return fmt.Errorf(
+		"%w: %s: unmapped columns: [%s], unfilled fields: [%s]",
+		ErrorStrictScan, typ, strings.Join(unmapped, `, `), strings.Join(unfilled, `, `),
+	)
 }
 
 func (b *DB) scanPointer(typ reflect.Type, columns []string, ctypes []*sql.ColumnType) (*rowScan, error) {
@@ -892,16 +1606,14 @@ func (b *DB) scanPointer(typ reflect.Type, columns []string, ctypes []*sql.Colum
 	if err != nil {
 		return nil, err
 	}
-	w := rs.value
-	rs.value = func(vs ...any) (reflect.Value, error) {
-		v, err := w(vs...)
-		if err != nil {
-			return reflect.Value{}, err
+	scan := rs.scan
+	rs.scan = func(rows *sql.Rows, elem reflect.Value) error {
+		pv := reflect.New(typ)
+		if err := scan(rows, pv.Elem()); err != nil {
+			return err
 		}
-		rv := reflect.Indirect(v)
-		pv := reflect.New(rv.Type())
-		pv.Elem().Set(rv)
-		return pv, nil
+		elem.Set(pv)
+		return nil
 	}
 	return rs, nil
 }
@@ -909,43 +1621,36 @@ func (b *DB) scanPointer(typ reflect.Type, columns []string, ctypes []*sql.Colum
 func (b *DB) scanMap(typ reflect.Type, columns []string, ctypes []*sql.ColumnType) (*rowScan, error) {
 	rs := &rowScan{types: make([]reflect.Type, 0, len(ctypes))}
 
+	// holders are reused across rows, the values are copied into map
+	holders := make([]any, 0, len(ctypes))
 	for _, ty := range ctypes {
-		switch ty.ScanType() {
-		case timeType, timePtrType:
-			rs.types = append(rs.types, nullTimeType)
-		case stringType:
-			rs.types = append(rs.types, nullStringType)
-		case boolType:
-			rs.types = append(rs.types, nullBoolType)
-		case int64Type:
-			rs.types = append(rs.types, nullInt64Type)
-		case byteType:
-			rs.types = append(rs.types, nullByteType)
-		case float64Type:
-			rs.types = append(rs.types, nullFloat64Type)
-		case int16Type:
-			rs.types = append(rs.types, nullInt16Type)
-		case int32Type:
-			rs.types = append(rs.types, nullInt32Type)
-		default:
-			rs.types = append(rs.types, ty.ScanType())
-		}
+		nullType := nullTypeOf(ty)
+		rs.types = append(rs.types, nullType)
+		holders = append(holders, reflect.New(nullType).Interface())
+	}
+
+	keys := make([]reflect.Value, len(columns))
+	for i, column := range columns {
+		keys[i] = reflect.ValueOf(column)
 	}
 
-	rs.value = func(vs ...any) (reflect.Value, error) {
-		mv := reflect.MakeMap(typ)
+	rs.scan = func(rows *sql.Rows, elem reflect.Value) error {
+		if err := rows.Scan(holders...); err != nil {
+			return err
+		}
 
-		for i, v := range vs {
-			rv := reflect.Indirect(reflect.ValueOf(v))
+		mv := reflect.MakeMapWithSize(typ, len(columns))
+		for i, v := range holders {
+			rv := reflect.ValueOf(v).Elem()
 			switch {
 			case typ.Elem().Kind() == reflect.Interface || rv.Kind() == typ.Elem().Kind():
 			default:
 				continue
 			}
-			mv.SetMapIndex(reflect.ValueOf(columns[i]), rv)
+			mv.SetMapIndex(keys[i], rv)
 		}
-
-		return mv, nil
+		elem.Set(mv)
+		return nil
 	}
 
 	return rs, nil
@@ -1000,19 +1705,18 @@ func (b *DB) scanSlice(rows *sql.Rows, dest any) error {
 		return err
 	}
 
+	elemType := v.Type().Elem()
 	for rows.Next() {
-		vs := scan.values()
-
-		if err = rows.Scan(vs...); err != nil {
-			return err
-		}
-
-		rv, err := scan.value(vs...)
-		if err != nil {
+		// scan into the appended element directly, removed if failed
+		n := v.Len()
+		v.Set(reflect.Append(v, reflect.Zero(elemType)))
+		if err = scan.scan(rows, v.Index(n)); err != nil {
+			v.SetLen(n)
+			if nullErr := scan.nullError(rows, columns); nullErr != nil {
+				return nullErr
+			}
 			return err
 		}
-
-		v.Set(reflect.Append(v, rv))
 	}
 
 	return rows.Err()
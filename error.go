@@ -0,0 +1,204 @@
+package gobatis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+)
+
+// ErrorStatementTimeout matched by errors.Is when the statement is canceled by its timeout,
+// the timeout attribute of the statement or mapper, or WithDefaultTimeout.
+var ErrorStatementTimeout = errors.New(`gobatis: statement timeout`)
+
+const (
+	// PhaseBind error occurred while finding the mapper or binding args into statements
+	PhaseBind = `bind`
+	// PhasePrepare error occurred while the interceptors rewriting the prepared statements
+	PhasePrepare = `prepare`
+	// PhaseExec error occurred while the database running the statements
+	PhaseExec = `exec`
+	// PhaseScan error occurred while scanning the result into dest
+	PhaseScan = `scan`
+)
+
+// Error wraps the error returned through DB.Error with the statement context
+// use errors.Is or errors.As to check the raw driver error.
+//
+// forexample:
+//
+//	var e *gobatis.Error
+//	if errors.As(err, &e) {
+//		fmt.Println(e.MapperID, e.Phase, e.SQL)
+//	}
+type Error struct {
+	MapperID string
+	Phase    string
+	SQL      string
+	Args     []any
+	Err      error
+	// Timeout the timeout of the statement if it's exceeded, otherwise 0
+	Timeout time.Duration
+}
+
+func (e *Error) Error() string {
+	if e.MapperID == `` {
+		return fmt.Sprintf(`gobatis: %s: %v`, e.Phase, e.Err)
+	}
+	return fmt.Sprintf(`gobatis: mapper %s: %s: %v`, e.MapperID, e.Phase, e.Err)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+func (e *Error) Is(target error) bool {
+	return target == ErrorStatementTimeout && e.Timeout > 0
+}
+
+// wrapError wrap err with the statement context of db
+// ErrorNotFound is kept as it is, it is not a failure of the statement.
+func (b *DB) wrapError(phase string, err error) error {
+	if err == nil || err == ErrorNotFound {
+		return err
+	}
+	var e *Error
+	if errors.As(err, &e) {
+		return err
+	}
+
+	e = &Error{MapperID: b.mapperId, Phase: phase, Err: err}
+	if b.deadline.exceeded() {
+		e.Timeout = b.deadline.timeout
+	}
+	if b.bindVars != nil {
+		e.SQL, e.Args = b.bindVars.stateSql, maskArgs(b.bindVars.args, b.bindVars.masks)
+	}
+	return e
+}
+
+// driverCode sqlstate and vendor codes from driver errors
+type driverCode struct {
+	state  string // postgres sqlstate
+	mysql  int64  // mysql error number
+	mssql  int64  // sqlserver error number
+	sqlite int64  // sqlite (extended) result code
+}
+
+// driverErrorCode extract codes from the error chain, detected via interfaces and field names
+// rather than importing drivers:
+//
+//	github.com/jackc/pgx, github.com/lib/pq: SQLState() string
+//	github.com/microsoft/go-mssqldb: SQLErrorNumber() int32
+//	modernc.org/sqlite: Code() int
+//	github.com/go-sql-driver/mysql: Number field
+//	github.com/mattn/go-sqlite3: ExtendedCode field
+func driverErrorCode(err error) (code driverCode) {
+	for ; err != nil; err = errors.Unwrap(err) {
+		switch v := err.(type) {
+		case interface{ SQLState() string }:
+			code.state = v.SQLState()
+			return
+		case interface{ SQLErrorNumber() int32 }:
+			code.mssql = int64(v.SQLErrorNumber())
+			return
+		case interface{ Code() int }:
+			code.sqlite = int64(v.Code())
+			return
+		}
+
+		rv := reflect.Indirect(reflect.ValueOf(err))
+		if rv.Kind() != reflect.Struct {
+			continue
+		}
+		if f := rv.FieldByName(`Number`); f.IsValid() {
+			switch f.Kind() {
+			case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+				code.mysql = int64(f.Uint())
+				return
+			}
+		}
+		if f := rv.FieldByName(`ExtendedCode`); f.IsValid() {
+			switch f.Kind() {
+			case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+				code.sqlite = f.Int()
+				return
+			}
+		}
+	}
+	return
+}
+
+func (c driverCode) match(states []string, mysql, mssql, sqlite []int64) bool {
+	for _, state := range states {
+		if c.state != `` && c.state == state {
+			return true
+		}
+	}
+	for _, n := range mysql {
+		if c.mysql != 0 && c.mysql == n {
+			return true
+		}
+	}
+	for _, n := range mssql {
+		if c.mssql != 0 && c.mssql == n {
+			return true
+		}
+	}
+	for _, n := range sqlite {
+		// match either the extended result code or the primary result code
+		if c.sqlite != 0 && (c.sqlite == n || c.sqlite&0xff == n) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsUniqueViolation report whether err is caused by a unique or primary key constraint violation
+func IsUniqueViolation(err error) bool {
+	return driverErrorCode(err).match(
+		[]string{`23505`},
+		[]int64{1062, 1169, 1586},
+		[]int64{2601, 2627},
+		[]int64{2067, 1555},
+	)
+}
+
+// IsForeignKeyViolation report whether err is caused by a foreign key constraint violation
+func IsForeignKeyViolation(err error) bool {
+	return driverErrorCode(err).match(
+		[]string{`23503`},
+		[]int64{1216, 1217, 1451, 1452},
+		[]int64{547},
+		[]int64{787},
+	)
+}
+
+// IsDeadlock report whether err is caused by a deadlock
+func IsDeadlock(err error) bool {
+	return driverErrorCode(err).match(
+		[]string{`40P01`},
+		[]int64{1213},
+		[]int64{1205},
+		[]int64{6},
+	)
+}
+
+// IsTimeout report whether err is caused by context deadline, network timeout,
+// statement timeout or lock timeout.
+func IsTimeout(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var timeout interface{ Timeout() bool }
+	if errors.As(err, &timeout) && timeout.Timeout() {
+		return true
+	}
+	return driverErrorCode(err).match(
+		[]string{`57014`, `55P03`},
+		[]int64{1205, 3024},
+		[]int64{1222},
+		[]int64{5},
+	)
+}
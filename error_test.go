@@ -0,0 +1,150 @@
+package gobatis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+// the errors of the drivers, mirroring how they expose the codes
+
+// pgError github.com/jackc/pgx, github.com/lib/pq
+type pgError struct{ code string }
+
+func (e *pgError) Error() string    { return `pg: ` + e.code }
+func (e *pgError) SQLState() string { return e.code }
+
+// mssqlError github.com/microsoft/go-mssqldb
+type mssqlError struct{ number int32 }
+
+func (e mssqlError) Error() string         { return fmt.Sprintf(`mssql: %d`, e.number) }
+func (e mssqlError) SQLErrorNumber() int32 { return e.number }
+
+// moderncError modernc.org/sqlite
+type moderncError struct{ code int }
+
+func (e *moderncError) Error() string { return fmt.Sprintf(`sqlite: %d`, e.code) }
+func (e *moderncError) Code() int     { return e.code }
+
+// mysqlError github.com/go-sql-driver/mysql
+type mysqlError struct {
+	Number  uint16
+	Message string
+}
+
+func (e *mysqlError) Error() string { return fmt.Sprintf(`mysql: %d`, e.Number) }
+
+// sqlite3Error github.com/mattn/go-sqlite3
+type sqlite3ErrNoExtended int
+
+type sqlite3Error struct {
+	Code         int
+	ExtendedCode sqlite3ErrNoExtended
+}
+
+func (e sqlite3Error) Error() string { return fmt.Sprintf(`sqlite3: %d`, e.ExtendedCode) }
+
+func TestDriverErrorCode(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want driverCode
+	}{
+		{`sqlstate`, &pgError{code: `23505`}, driverCode{state: `23505`}},
+		{`mssql`, mssqlError{number: 2627}, driverCode{mssql: 2627}},
+		{`modernc`, &moderncError{code: 2067}, driverCode{sqlite: 2067}},
+		{`mysql`, &mysqlError{Number: 1062}, driverCode{mysql: 1062}},
+		{`sqlite3`, sqlite3Error{Code: 19, ExtendedCode: 2067}, driverCode{sqlite: 2067}},
+		{`wrapped`, fmt.Errorf(`insert: %w`, &pgError{code: `23503`}), driverCode{state: `23503`}},
+		{`gobatis error`, &Error{Phase: PhaseExec, Err: &mysqlError{Number: 1213}}, driverCode{mysql: 1213}},
+		{`unknown`, errors.New(`unknown`), driverCode{}},
+		{`nil`, nil, driverCode{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := driverErrorCode(tt.err); got != tt.want {
+				t.Errorf(`driverErrorCode() = %+v, want %+v`, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsUniqueViolation(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{`postgres`, &pgError{code: `23505`}, true},
+		{`postgres foreign key`, &pgError{code: `23503`}, false},
+		{`mysql`, &mysqlError{Number: 1062}, true},
+		{`mysql other`, &mysqlError{Number: 1064}, false},
+		{`sqlserver unique index`, mssqlError{number: 2601}, true},
+		{`sqlserver primary key`, mssqlError{number: 2627}, true},
+		{`sqlite unique`, sqlite3Error{Code: 19, ExtendedCode: 2067}, true},
+		{`sqlite primary key`, &moderncError{code: 1555}, true},
+		{`sqlite not null`, sqlite3Error{Code: 19, ExtendedCode: 1299}, false},
+		{`wrapped`, &Error{Phase: PhaseExec, Err: fmt.Errorf(`%w`, &pgError{code: `23505`})}, true},
+		{`plain`, errors.New(`duplicate key`), false},
+		{`nil`, nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsUniqueViolation(tt.err); got != tt.want {
+				t.Errorf(`IsUniqueViolation() = %v, want %v`, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestErrorClassification(t *testing.T) {
+	if !IsForeignKeyViolation(&pgError{code: `23503`}) || IsForeignKeyViolation(&pgError{code: `23505`}) {
+		t.Error(`IsForeignKeyViolation() mismatched the sqlstate`)
+	}
+	if !IsDeadlock(&mysqlError{Number: 1213}) || !IsDeadlock(mssqlError{number: 1205}) {
+		t.Error(`IsDeadlock() missed the deadlock`)
+	}
+	if !IsTimeout(fmt.Errorf(`query: %w`, context.DeadlineExceeded)) || !IsTimeout(&pgError{code: `57014`}) {
+		t.Error(`IsTimeout() missed the timeout`)
+	}
+	if IsTimeout(errors.New(`timeout`)) {
+		t.Error(`IsTimeout() matched the plain error`)
+	}
+}
+
+func TestWrapError(t *testing.T) {
+	db := &DB{mapperId: `users.find`, bindVars: &BindVar{stateSql: `select 1`, args: []any{1}}}
+	cause := &pgError{code: `23505`}
+
+	err := db.wrapError(PhaseExec, cause)
+	var e *Error
+	if !errors.As(err, &e) || e.MapperID != `users.find` || e.SQL != `select 1` || !errors.Is(err, cause) {
+		t.Fatalf(`wrapError() = %#v`, err)
+	}
+	if again := db.wrapError(PhaseScan, err); again != err {
+		t.Errorf(`wrapError() wrapped twice: %v`, again)
+	}
+	if err := db.wrapError(PhaseExec, ErrorNotFound); err != ErrorNotFound {
+		t.Errorf(`wrapError() = %v, want %v`, err, ErrorNotFound)
+	}
+}
+
+func TestExecError(t *testing.T) {
+	db, server := openTest(t, `<mapper>
+<insert id="insert">insert into users (name) values (#{name})</insert>
+</mapper>`)
+	server.setErr(&pgError{code: `23505`})
+
+	err := db.Mapper(`insert`).Args(Args{`name`: `a`}).Execute().Error
+	var e *Error
+	if !errors.As(err, &e) {
+		t.Fatalf(`Execute() error = %#v, want *Error`, err)
+	}
+	if e.MapperID != `insert` || e.Phase != PhaseExec || e.SQL != `insert into users (name) values (?)` {
+		t.Errorf(`Execute() error = %+v`, e)
+	}
+	if !IsUniqueViolation(err) {
+		t.Errorf(`IsUniqueViolation(%v) = false`, err)
+	}
+}
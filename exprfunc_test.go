@@ -0,0 +1,58 @@
+package gobatis
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type roleKey struct{}
+
+const exprFuncMapper = `<mapper>
+<select id="find">select * from users where <if test="!isBlank(name) and hasRole(ctx, 'admin')">name = #{upper(name)} and </if>limit ${MaxPageSize}</select>
+<select id="missing">select * from users where id = #{nope(id)}</select>
+</mapper>`
+
+func TestExprFunctions(t *testing.T) {
+	db, _ := openTest(t, exprFuncMapper,
+		WithExprFunctions(map[string]any{
+			`isBlank`: func(s string) bool { return strings.TrimSpace(s) == `` },
+			`hasRole`: func(ctx context.Context, role string) bool { return ctx.Value(roleKey{}) == role },
+			`upper`:   strings.ToUpper,
+		}),
+		WithExprConstants(map[string]any{`MaxPageSize`: 100}),
+	)
+	admin := db.WithContext(context.WithValue(context.Background(), roleKey{}, `admin`))
+
+	tests := []struct {
+		name      string
+		db        *DB
+		variables Args
+		statement string
+		args      []interface{}
+	}{
+		{`functions`, admin, Args{`name`: `a`}, `select * from users where name = ? and limit 100`, []interface{}{`A`}},
+		{`blank`, admin, Args{`name`: ` `}, `select * from users where limit 100`, nil},
+		{`ctx`, db, Args{`name`: `a`}, `select * from users where limit 100`, nil},
+		{`args take precedence`, admin, Args{`name`: ` `, `MaxPageSize`: 10}, `select * from users where limit 10`, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			statement, args, err := tt.db.Mapper(`find`).Render(tt.variables)
+			if err != nil {
+				t.Fatalf(`Render() error = %v`, err)
+			}
+			if statement != tt.statement {
+				t.Errorf("Render()\n got: %q\nwant: %q", statement, tt.statement)
+			}
+			if (len(args) != 0 || len(tt.args) != 0) && !reflect.DeepEqual(args, tt.args) {
+				t.Errorf(`Render() args = %#v, want %#v`, args, tt.args)
+			}
+		})
+	}
+
+	if _, _, err := db.Mapper(`missing`).Render(Args{`id`: 1}); err == nil || !strings.Contains(err.Error(), `gobatis: function not define: nope`) {
+		t.Errorf(`Render() with undefined function error = %v`, err)
+	}
+}
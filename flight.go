@@ -0,0 +1,90 @@
+package gobatis
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+var errorSharedQueryPanic = errors.New(`gobatis: shared query panicked`)
+
+// flightGroup collapse the concurrent calls of the same key into one
+type flightGroup struct {
+	mu    sync.Mutex
+	calls map[string]*flightCall
+}
+
+type flightCall struct {
+	done  chan struct{}
+	value reflect.Value
+	err   error
+}
+
+// do scan the result of key into dest, only the first caller runs fn, the others wait for it
+// and receive a copy of the result. the slice result is appended to dest like scanning.
+// the waiting callers run fn themselves if the first caller failed by its own cancellation or timeout,
+// and stop waiting when ctx is done.
+func (g *flightGroup) do(ctx context.Context, key string, dest any, fn func(dest any) error) error {
+	dv := reflect.ValueOf(dest).Elem()
+
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = make(map[string]*flightCall)
+	}
+	if c, ok := g.calls[key]; ok {
+		g.mu.Unlock()
+		select {
+		case <-c.done:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		if c.err != nil {
+			if contextError(c.err) && ctx.Err() == nil {
+				// share the query of another caller again
+				return g.do(ctx, key, dest, fn)
+			}
+			return c.err
+		}
+		mergeResult(dv, cloneValue(c.value))
+		return nil
+	}
+	c := &flightCall{done: make(chan struct{}), err: errorSharedQueryPanic}
+	g.calls[key] = c
+	g.mu.Unlock()
+
+	defer func() {
+		g.mu.Lock()
+		delete(g.calls, key)
+		g.mu.Unlock()
+		close(c.done)
+	}()
+
+	result := reflect.New(dv.Type())
+	c.err = fn(result.Interface())
+	c.value = result.Elem()
+	if c.err != nil {
+		return c.err
+	}
+	mergeResult(dv, cloneValue(c.value))
+	return nil
+}
+
+// contextError report whether err is caused by the cancellation or timeout of the caller
+func contextError(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) ||
+		errors.Is(err, ErrorStatementTimeout)
+}
+
+// isShared report whether the select is shared with the concurrent identical ones
+func (b *DB) isShared() bool {
+	if b.flights == nil || b.tx != nil {
+		return false
+	}
+	if b.sharedQueries {
+		return true
+	}
+	value, _ := b.mapperAttr(SharedKey)
+	return strings.EqualFold(value, `true`)
+}
@@ -0,0 +1,136 @@
+package gobatis
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+const flightMapper = `<mapper>
+<select id="find" shared="true">select * from users where id = #{id}</select>
+<select id="unshared">select * from users where id = #{id}</select>
+</mapper>`
+
+// findConcurrently run n concurrent Find of the mapper, and return the results
+func findConcurrently(db *DB, id string, n int) ([][]scanUser, []error) {
+	var wg sync.WaitGroup
+	results, errs := make([][]scanUser, n), make([]error, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = db.Mapper(id).Args(Args{`id`: 1}).Find(&results[i]).Error
+		}(i)
+	}
+	wg.Wait()
+	return results, errs
+}
+
+func TestSharedQuery(t *testing.T) {
+	db, server := openTest(t, flightMapper)
+	server.setRows([]string{`id`, `name`}, []driver.Value{int64(1), `a`})
+	// the callers arrive while the first query is running
+	server.setDelay(200 * time.Millisecond)
+
+	results, errs := findConcurrently(db, `find`, 5)
+	for i := range results {
+		if errs[i] != nil || len(results[i]) != 1 || results[i][0].Name != `a` {
+			t.Fatalf(`Find() = %+v, %v`, results[i], errs[i])
+		}
+	}
+	if n := server.count(`select * from users where id = ?`); n != 1 {
+		t.Errorf(`queried %d times, want 1`, n)
+	}
+	// each caller receives an independent copy
+	results[0][0].Name = `modified`
+	if results[1][0].Name != `a` {
+		t.Error(`the shared result is not copied`)
+	}
+
+	findConcurrently(db, `unshared`, 3)
+	if n := server.count(`select * from users where id = ?`); n != 4 {
+		t.Errorf(`queried %d times, want 4`, n)
+	}
+}
+
+func TestSharedQueryError(t *testing.T) {
+	db, server := openTest(t, flightMapper, WithSharedQueries())
+	cause := errors.New(`boom`)
+	server.setErr(cause)
+	server.setDelay(200 * time.Millisecond)
+
+	_, errs := findConcurrently(db, `unshared`, 3)
+	for _, err := range errs {
+		if !errors.Is(err, cause) {
+			t.Errorf(`Find() error = %v, want %v`, err, cause)
+		}
+	}
+	if n := server.count(`select * from users where id = ?`); n != 1 {
+		t.Errorf(`queried %d times, want 1`, n)
+	}
+}
+
+func TestSharedQueryTransaction(t *testing.T) {
+	db, server := openTest(t, flightMapper)
+	server.setRows([]string{`id`, `name`}, []driver.Value{int64(1), `a`})
+
+	// the queries of transaction are never shared
+	err := db.Transaction(func(tx *DB) error {
+		var users []scanUser
+		return tx.Mapper(`find`).Args(Args{`id`: 1}).Find(&users).Error
+	})
+	if err != nil {
+		t.Fatalf(`Transaction() error = %v`, err)
+	}
+	if n := server.count(`select * from users where id = ?`); n != 1 {
+		t.Errorf(`queried %d times, want 1`, n)
+	}
+}
+
+func TestSharedQueryCancel(t *testing.T) {
+	db, server := openTest(t, flightMapper)
+	server.setRows([]string{`id`, `name`}, []driver.Value{int64(1), `a`})
+	server.setDelay(200 * time.Millisecond)
+
+	// the first caller gives up, the waiting callers query again
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	leader := make(chan error, 1)
+	go func() {
+		var users []scanUser
+		leader <- db.WithContext(ctx).Mapper(`find`).Args(Args{`id`: 1}).Find(&users).Error
+	}()
+	time.Sleep(20 * time.Millisecond)
+
+	results, errs := findConcurrently(db, `find`, 3)
+	if err := <-leader; !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf(`Find() error = %v, want %v`, err, context.DeadlineExceeded)
+	}
+	for i := range results {
+		if errs[i] != nil || len(results[i]) != 1 {
+			t.Errorf(`Find() = %+v, %v`, results[i], errs[i])
+		}
+	}
+	if n := server.count(`select * from users where id = ?`); n != 2 {
+		t.Errorf(`queried %d times, want 2`, n)
+	}
+
+	// the waiting caller gives up without failing the others
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+		defer cancel()
+		var users []scanUser
+		leader <- db.WithContext(ctx).Mapper(`find`).Args(Args{`id`: 1}).Find(&users).Error
+	}()
+	results, errs = findConcurrently(db, `find`, 1)
+	if err := <-leader; !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf(`Find() error = %v, want %v`, err, context.DeadlineExceeded)
+	}
+	if errs[0] != nil || len(results[0]) != 1 {
+		t.Errorf(`Find() = %+v, %v`, results[0], errs[0])
+	}
+}
@@ -3,6 +3,8 @@ package gobatis
 import (
 	"encoding/xml"
 	"strings"
+
+	"github.com/fbatis/expr/vm"
 )
 
 type Foreach struct {
@@ -10,6 +12,9 @@ type Foreach struct {
 	Attrs    []xml.Attr
 	AttrsMap map[string]string
 	Sql      []*Sql
+
+	// program compiled from the collection attribute
+	program *vm.Program
 }
 
 func NewForeach() *Foreach {
@@ -26,6 +31,11 @@ func (m *Foreach) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
 	for _, attr := range start.Attr {
 		m.AttrsMap[XmlName(attr.Name).Name()] = strings.TrimSpace(attr.Value)
 	}
+	if program, err := compileAttr(m.AttrsMap, CollectionKey); err != nil {
+		return err
+	} else {
+		m.program = program
+	}
 
 	for {
 		tok, err := d.Token()
@@ -40,7 +50,11 @@ func (m *Foreach) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
 				m.Children = append(m.Children, ele)
 			}
 		case xml.CharData:
-			m.Children = append(m.Children, el.Copy())
+			if text, err := NewText(el.Copy()); err != nil {
+				return err
+			} else {
+				m.Children = append(m.Children, text)
+			}
 		case xml.EndElement:
 			return nil
 		case xml.Comment, xml.ProcInst, xml.Directive:
@@ -0,0 +1,96 @@
+package gobatis
+
+import (
+	"strings"
+	"unicode"
+)
+
+// fragment piece of the rendered statement, either literal text or a parameter slot
+type fragment struct {
+	text string
+
+	// slot is a #{} parameter, value is bound as prepared statement args
+	slot   bool
+	value  any
+	secret bool
+}
+
+// Fragments the rendered statement, a stream of literal text and parameter slots
+// the placeholders and args are assigned when the whole statement is rendered.
+type Fragments struct {
+	parts []fragment
+}
+
+// WriteString append literal text
+func (f *Fragments) WriteString(text string) {
+	if text != `` {
+		f.parts = append(f.parts, fragment{text: text})
+	}
+}
+
+// WriteParam append a parameter slot
+func (f *Fragments) WriteParam(value any, secret bool) {
+	f.parts = append(f.parts, fragment{slot: true, value: value, secret: secret})
+}
+
+// append the fragments of other
+func (f *Fragments) append(other *Fragments) {
+	f.parts = append(f.parts, other.parts...)
+}
+
+// empty report whether nothing but whitespaces rendered
+func (f *Fragments) empty() bool {
+	for _, part := range f.parts {
+		if part.slot || strings.TrimSpace(part.text) != `` {
+			return false
+		}
+	}
+	return true
+}
+
+// trimSpace remove the leading and trailing whitespaces of the literal text
+func (f *Fragments) trimSpace() {
+	for len(f.parts) > 0 && !f.parts[0].slot {
+		if f.parts[0].text = strings.TrimLeftFunc(f.parts[0].text, unicode.IsSpace); f.parts[0].text != `` {
+			break
+		}
+		f.parts = f.parts[1:]
+	}
+	for n := len(f.parts); n > 0 && !f.parts[n-1].slot; n = len(f.parts) {
+		if f.parts[n-1].text = strings.TrimRightFunc(f.parts[n-1].text, unicode.IsSpace); f.parts[n-1].text != `` {
+			break
+		}
+		f.parts = f.parts[:n-1]
+	}
+}
+
+// leading merge the literal text before the first slot, and return it
+func (f *Fragments) leading() string {
+	n := 0
+	for n < len(f.parts) && !f.parts[n].slot {
+		n++
+	}
+	switch n {
+	case 0:
+		return ``
+	case 1:
+		return f.parts[0].text
+	}
+
+	var builder strings.Builder
+	for _, part := range f.parts[:n] {
+		builder.WriteString(part.text)
+	}
+	f.parts = append([]fragment{{text: builder.String()}}, f.parts[n:]...)
+	return f.parts[0].text
+}
+
+// trimPrefix remove prefix of the leading text, case-insensitive
+func (f *Fragments) trimPrefix(prefix string) bool {
+	text := f.leading()
+	if prefix == `` || !strings.HasPrefix(strings.ToLower(text), prefix) {
+		return false
+	}
+	f.parts[0].text = text[len(prefix):]
+	return true
+}
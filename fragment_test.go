@@ -0,0 +1,70 @@
+package gobatis
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFragments(t *testing.T) {
+	var f Fragments
+	f.WriteString("  \n\t")
+	f.WriteString(`AND `)
+	f.WriteString(`id = `)
+	f.WriteParam(1, false)
+	f.WriteString(`  `)
+
+	f.trimSpace()
+	if !f.trimPrefix(`and`) || f.trimPrefix(`or`) {
+		t.Fatalf(`trimPrefix() = %+v`, f.parts)
+	}
+	want := []fragment{{text: ` id = `}, {slot: true, value: 1}}
+	if !reflect.DeepEqual(f.parts, want) {
+		t.Errorf(`parts = %+v, want %+v`, f.parts, want)
+	}
+	if f.empty() {
+		t.Error(`empty() = true with a slot`)
+	}
+}
+
+func TestRenderFragments(t *testing.T) {
+	db, _ := openTest(t, `<mapper type="postgres">
+<select id="where">select * from users <where><if test="id != nil">and id = #{id}</if><if test="name != nil">or name = #{name}</if></where></select>
+<select id="trim">select * from users where <trim prefixOverrides="AND | OR"><if test="id != nil">OR id = #{id}</if> and tag = #{tag}</trim></select>
+<select id="raw">select ${columns} from users where id = #{id}</select>
+</mapper>`)
+
+	tests := []struct {
+		name      string
+		id        string
+		variables Args
+		statement string
+		args      []interface{}
+	}{
+		{`where and`, `where`, Args{`id`: 1, `name`: nil}, `select * from users WHERE id = $1`, []interface{}{1}},
+		{`where or`, `where`, Args{`id`: nil, `name`: `a`}, `select * from users WHERE name = $1`, []interface{}{`a`}},
+		{`where empty`, `where`, Args{`id`: nil, `name`: nil}, `select * from users`, nil},
+		{`trim`, `trim`, Args{`id`: nil, `tag`: `x`}, `select * from users where tag = $1`, []interface{}{`x`}},
+		{`trim or`, `trim`, Args{`id`: 1, `tag`: `x`}, `select * from users where id = $1 and tag = $2`, []interface{}{1, `x`}},
+		// placeholders rendered from ${} values are literal text, not parameters
+		{`raw placeholder text`, `raw`, Args{`columns`: `'#{id}', '$1'`, `id`: 2}, `select '#{id}', '$1' from users where id = $1`, []interface{}{2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			statement, args, err := db.Mapper(tt.id).Render(tt.variables)
+			if err != nil {
+				t.Fatalf(`Render() error = %v`, err)
+			}
+			if statement != tt.statement {
+				t.Errorf("Render() statement\n got: %q\nwant: %q", statement, tt.statement)
+			}
+			if (len(args) != 0 || len(tt.args) != 0) && !reflect.DeepEqual(args, tt.args) {
+				t.Errorf(`Render() args = %#v, want %#v`, args, tt.args)
+			}
+		})
+	}
+
+	if _, _, err := db.Mapper(`raw`).Render(Args{`id`: 1}); err == nil || !strings.Contains(err.Error(), `columns`) {
+		t.Errorf(`Render() without columns error = %v`, err)
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"encoding/xml"
 	"strings"
+
+	"github.com/fbatis/expr/vm"
 )
 
 const (
@@ -16,6 +18,14 @@ const (
 	IdKey              = `id`
 	TypeKey            = `type`
 	IndexKey           = `index`
+	StrictKey          = `strict`
+	NamespaceKey       = `namespace`
+	CacheKey           = `cache`
+	TTLKey             = `ttl`
+	SharedKey          = `shared`
+	TimeoutKey         = `timeout`
+	SlowKey            = `slow`
+	MasterKey          = `master`
 )
 
 type If struct {
@@ -23,6 +33,9 @@ type If struct {
 	Attrs    []xml.Attr
 	AttrsMap map[string]string
 	Sql      []*Sql
+
+	// program compiled from the test attribute
+	program *vm.Program
 }
 
 func NewIf() *If {
@@ -33,8 +46,12 @@ func NewIf() *If {
 	}
 }
 
-func (m *If) Evaluate(ctx context.Context, input *HandlerPayload) (string, error) {
-	return intervalEvaluate(ctx, m.Children, input)
+func (m *If) Evaluate(ctx context.Context, input *HandlerPayload) (*Fragments, error) {
+	var out Fragments
+	if err := intervalEvaluate(ctx, m.Children, input, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
 }
 
 func (m *If) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
@@ -42,6 +59,11 @@ func (m *If) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
 	for _, attr := range m.Attrs {
 		m.AttrsMap[XmlName(attr.Name).Name()] = strings.TrimSpace(attr.Value)
 	}
+	if program, err := compileAttr(m.AttrsMap, TestKey); err != nil {
+		return err
+	} else {
+		m.program = program
+	}
 
 	for {
 		tok, err := d.Token()
@@ -56,7 +78,11 @@ func (m *If) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
 				m.Children = append(m.Children, ele)
 			}
 		case xml.CharData:
-			m.Children = append(m.Children, el.Copy())
+			if text, err := NewText(el.Copy()); err != nil {
+				return err
+			} else {
+				m.Children = append(m.Children, text)
+			}
 		case xml.EndElement:
 			return nil
 		case xml.Comment, xml.ProcInst, xml.Directive:
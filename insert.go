@@ -24,8 +24,12 @@ func NewInsert() *Insert {
 	}
 }
 
-func (m *Insert) Evaluate(ctx context.Context, input *HandlerPayload) (string, error) {
-	return intervalEvaluate(ctx, m.Children, input)
+func (m *Insert) Evaluate(ctx context.Context, input *HandlerPayload) (*Fragments, error) {
+	var out Fragments
+	if err := intervalEvaluate(ctx, m.Children, input, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
 }
 
 func (m *Insert) Bind(ctx context.Context, input *HandlerPayload) *BindVar {
@@ -51,7 +55,11 @@ func (m *Insert) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
 				m.Children = append(m.Children, ele)
 			}
 		case xml.CharData:
-			m.Children = append(m.Children, el.Copy())
+			if text, err := NewText(el.Copy()); err != nil {
+				return err
+			} else {
+				m.Children = append(m.Children, text)
+			}
 		case xml.EndElement:
 			return nil
 		case xml.Comment, xml.ProcInst, xml.Directive:
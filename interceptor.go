@@ -0,0 +1,139 @@
+package gobatis
+
+import (
+	"context"
+)
+
+// statement types of Invocation.Type
+const (
+	StatementSelect = `select`
+	StatementInsert = `insert`
+	StatementUpdate = `update`
+	StatementDelete = `delete`
+	// StatementRaw statements of RawQuery and RawExec
+	StatementRaw = `raw`
+)
+
+// Invocation the statement passing through the interceptors
+type Invocation struct {
+	Ctx      context.Context
+	MapperID string
+	// Type StatementSelect, StatementInsert, StatementUpdate, StatementDelete or StatementRaw
+	Type string
+
+	// Input variables to render, can be replaced in PhaseBind before next called,
+	// replace it with a copy rather than modifying the caller's variables.
+	Input any
+
+	// SQL and Args the prepared statement, can be rewritten in PhasePrepare,
+	// the sensitive args are wrapped by Secret, keep them wrapped to be masked in logs.
+	SQL  string
+	Args []any
+
+	// Dest scan destination in PhaseScan, can be post-processed after next returned
+	Dest any
+
+	// RowsAffected and LastInsertId results of the insert, update and delete statements in PhaseExec,
+	// can be modified after next returned
+	RowsAffected int64
+	LastInsertId int64
+}
+
+// Interceptor called around the phases of each statement, in the spirit of MyBatis plugins:
+//
+//	PhaseBind: rendering the mapper with Input
+//	PhasePrepare: after rendering, the SQL and Args are to be executed
+//	PhaseExec: executing the query or exec on database
+//	PhaseScan: scanning the rows into Dest
+//
+// call next to continue the phase, return error without calling next to abort the statement.
+type Interceptor interface {
+	Intercept(phase string, inv *Invocation, next func() error) error
+}
+
+// InterceptorFunc adapter to use ordinary function as Interceptor
+//
+// forexample:
+//
+//	gobatis.WithInterceptors(gobatis.InterceptorFunc(func(phase string, inv *gobatis.Invocation, next func() error) error {
+//		if phase == gobatis.PhasePrepare && inv.Type == gobatis.StatementSelect {
+//			inv.SQL = `/* app */ ` + inv.SQL
+//		}
+//		return next()
+//	}))
+type InterceptorFunc func(phase string, inv *Invocation, next func() error) error
+
+func (f InterceptorFunc) Intercept(phase string, inv *Invocation, next func() error) error {
+	return f(phase, inv, next)
+}
+
+// statementType type of the current statement
+func (b *DB) statementType() string {
+	if _, named := b.mapper.(*namedStatement); b.mapper == nil || named {
+		return StatementRaw
+	}
+	switch b.mapperType {
+	case mapperInsert:
+		return StatementInsert
+	case mapperUpdate:
+		return StatementUpdate
+	case mapperDelete:
+		return StatementDelete
+	default:
+		return StatementSelect
+	}
+}
+
+// invocation create the invocation of the current statement with the prepared statement of bindVars
+func (b *DB) invocation(bindVars *BindVar) *Invocation {
+	inv := &Invocation{Ctx: b.ctx, MapperID: b.mapperId, Type: b.statementType()}
+	if bindVars != nil {
+		inv.SQL, inv.Args = bindVars.stateSql, maskArgs(bindVars.args, bindVars.masks)
+	}
+	return inv
+}
+
+// intercept run fn through the interceptors of phase
+func (b *DB) intercept(phase string, inv *Invocation, fn func() error) error {
+	if len(b.interceptors) == 0 {
+		return fn()
+	}
+
+	var call func(i int) error
+	call = func(i int) error {
+		if i == len(b.interceptors) {
+			return fn()
+		}
+		return b.interceptors[i].Intercept(phase, inv, func() error {
+			return call(i + 1)
+		})
+	}
+	return call(0)
+}
+
+// prepare run the PhasePrepare interceptors, which may rewrite the sql and args of bindVars
+func (b *DB) prepare(bindVars *BindVar) (*BindVar, error) {
+	if len(b.interceptors) == 0 {
+		return bindVars, nil
+	}
+
+	inv := b.invocation(bindVars)
+	if err := b.intercept(PhasePrepare, inv, func() error { return nil }); err != nil {
+		return nil, b.wrapError(PhasePrepare, err)
+	}
+	args, masks := unwrapSecrets(inv.Args)
+	return &BindVar{stateSql: inv.SQL, args: args, masks: masks, typ: bindVars.typ, err: bindVars.err}, nil
+}
+
+// scanRows scan the rows into dest through the PhaseScan interceptors
+func (db *DB) scanRows(dest any) error {
+	if len(db.interceptors) == 0 {
+		return db.scan(db.rows, dest)
+	}
+
+	inv := db.invocation(db.bindVars)
+	inv.Dest = dest
+	return db.intercept(PhaseScan, inv, func() error {
+		return db.scan(db.rows, inv.Dest)
+	})
+}
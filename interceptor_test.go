@@ -0,0 +1,152 @@
+package gobatis
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+const interceptorMapper = `<mapper>
+<select id="find">select * from users where id = #{id}</select>
+<update id="update">update users set name = #{name}</update>
+</mapper>`
+
+// traceInterceptor record the phases around next
+type traceInterceptor struct {
+	name  string
+	mu    *sync.Mutex
+	trace *[]string
+}
+
+func (i traceInterceptor) Intercept(phase string, inv *Invocation, next func() error) error {
+	i.record(fmt.Sprintf(`%s:%s:%s>`, i.name, phase, inv.Type))
+	err := next()
+	i.record(fmt.Sprintf(`<%s:%s`, i.name, phase))
+	return err
+}
+
+func (i traceInterceptor) record(s string) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	*i.trace = append(*i.trace, s)
+}
+
+func TestInterceptorOrder(t *testing.T) {
+	var mu sync.Mutex
+	var trace []string
+	db, server := openTest(t, interceptorMapper, WithInterceptors(
+		traceInterceptor{`a`, &mu, &trace},
+		traceInterceptor{`b`, &mu, &trace},
+	))
+	server.setRows([]string{`id`}, []driver.Value{int64(1)})
+
+	var users []scanUser
+	if err := db.Mapper(`find`).Args(Args{`id`: 1}).Find(&users).Error; err != nil {
+		t.Fatalf(`Find() error = %v`, err)
+	}
+	var want []string
+	for _, phase := range []string{PhaseBind, PhasePrepare, PhaseExec, PhaseScan} {
+		want = append(want, `a:`+phase+`:select>`, `b:`+phase+`:select>`, `<b:`+phase, `<a:`+phase)
+	}
+	if strings.Join(trace, ` `) != strings.Join(want, ` `) {
+		t.Errorf("trace\n got: %v\nwant: %v", trace, want)
+	}
+
+	trace = nil
+	if err := db.RawExec(`update users set name = ?`, `a`).Error; err != nil {
+		t.Fatalf(`RawExec() error = %v`, err)
+	}
+	want = []string{`a:prepare:raw>`, `b:prepare:raw>`, `<b:prepare`, `<a:prepare`, `a:exec:raw>`, `b:exec:raw>`, `<b:exec`, `<a:exec`}
+	if strings.Join(trace, ` `) != strings.Join(want, ` `) {
+		t.Errorf("trace\n got: %v\nwant: %v", trace, want)
+	}
+}
+
+func TestInterceptorShortCircuit(t *testing.T) {
+	denied := errors.New(`denied`)
+	var inner int
+	db, server := openTest(t, interceptorMapper, WithInterceptors(
+		InterceptorFunc(func(phase string, inv *Invocation, next func() error) error {
+			if phase == PhaseExec && inv.Type == StatementUpdate {
+				return denied
+			}
+			return next()
+		}),
+		InterceptorFunc(func(phase string, inv *Invocation, next func() error) error {
+			if phase == PhaseExec {
+				inner++
+			}
+			return next()
+		}),
+	))
+
+	err := db.Mapper(`update`).Args(Args{`name`: `a`}).Execute().Error
+	if !errors.Is(err, denied) {
+		t.Fatalf(`Execute() error = %v, want %v`, err, denied)
+	}
+	var e *Error
+	if !errors.As(err, &e) || e.Phase != PhaseExec || e.MapperID != `update` {
+		t.Errorf(`Execute() error = %#v`, err)
+	}
+	if statements := server.log(); len(statements) != 0 || inner != 0 {
+		t.Errorf(`executed %v after aborted, inner interceptor called %d times`, statements, inner)
+	}
+}
+
+func TestInterceptorRewrite(t *testing.T) {
+	var prepared []any
+	db, server := openTest(t, interceptorMapper, WithInterceptors(
+		InterceptorFunc(func(phase string, inv *Invocation, next func() error) error {
+			switch phase {
+			case PhaseBind:
+				inv.Input = Args{`id`: 2, `name`: `b`}
+			case PhasePrepare:
+				inv.SQL = `/* app */ ` + inv.SQL
+				inv.Args = append(inv.Args, `tenant`)
+			case PhaseExec:
+				err := next()
+				inv.RowsAffected *= 10
+				return err
+			case PhaseScan:
+				err := next()
+				users := inv.Dest.(*[]scanUser)
+				for i := range *users {
+					(*users)[i].Name = strings.ToUpper((*users)[i].Name)
+				}
+				return err
+			}
+			return next()
+		}),
+		InterceptorFunc(func(phase string, inv *Invocation, next func() error) error {
+			if phase == PhaseExec {
+				prepared = inv.Args
+			}
+			return next()
+		}),
+	))
+	server.setRows([]string{`id`, `name`}, []driver.Value{int64(2), `b`})
+
+	var users []scanUser
+	if err := db.Mapper(`find`).Args(Args{`id`: 1}).Find(&users).Error; err != nil {
+		t.Fatalf(`Find() error = %v`, err)
+	}
+	if len(users) != 1 || users[0].Name != `B` {
+		t.Errorf(`Find() = %+v`, users)
+	}
+	if want := []any{2, `tenant`}; !reflect.DeepEqual(prepared, want) {
+		t.Errorf(`args = %v, want %v`, prepared, want)
+	}
+
+	ndb := db.Mapper(`update`).Args(Args{`name`: `a`}).Execute()
+	if ndb.Error != nil || ndb.RowsAffected != 10 {
+		t.Errorf(`Execute() = %d, %v, want 10`, ndb.RowsAffected, ndb.Error)
+	}
+	want := []string{`/* app */ select * from users where id = ?`, `/* app */ update users set name = ?`}
+	if got := server.log(); !reflect.DeepEqual(got, want) {
+		t.Errorf("executed\n got: %q\nwant: %q", got, want)
+	}
+}
@@ -0,0 +1,205 @@
+package gobatis
+
+import (
+	"database/sql/driver"
+	"encoding/hex"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	literalMysql = iota
+	literalPostgres
+	// literalStandard standard sql literals of sqlite and the databases not known
+	literalStandard
+	literalSqlserver
+	literalOracle
+)
+
+// Interpolate inline args into the prepared statement as literals of the database type
+// the result is used for debugging only, such as copy-pasting into psql or mysql clients,
+// never execute it, use the prepared statement and it's args instead.
+func Interpolate(typ string, statement string, args ...any) (string, error) {
+	var (
+		builder strings.Builder
+		count   int
+		style   = literalStyleOf(dialectOf(typ))
+		used    = make([]bool, len(args))
+	)
+	builder.Grow(len(statement) + len(args)*8)
+
+	for i := 0; i < len(statement); i++ {
+		// the placeholders in quoted strings and comments are kept as they are
+		if end := literalEnd(statement, i, style); end > 0 {
+			builder.WriteString(statement[i:end])
+			i = end - 1
+			continue
+		}
+
+		c := statement[i]
+		switch {
+		case c == '?' && style != literalPostgres && style != literalSqlserver && style != literalOracle:
+			if count >= len(args) {
+				return ``, fmt.Errorf(`gobatis: Interpolate: missing arg for placeholder %d`, count+1)
+			}
+			literal, err := formatLiteral(style, args[count])
+			if err != nil {
+				return ``, err
+			}
+			builder.WriteString(literal)
+			used[count] = true
+			count++
+		case c == '$' && style == literalPostgres,
+			c == ':' && style == literalOracle,
+			c == '@' && style == literalSqlserver && strings.HasPrefix(statement[i+1:], `p`):
+			start := i + 1
+			if c == '@' {
+				start++
+			}
+			end := start
+			for end < len(statement) && statement[end] >= '0' && statement[end] <= '9' {
+				end++
+			}
+			if end == start {
+				builder.WriteByte(c)
+				continue
+			}
+			n, _ := strconv.Atoi(statement[start:end])
+			if n < 1 || n > len(args) {
+				return ``, fmt.Errorf(`gobatis: Interpolate: missing arg for placeholder %d`, n)
+			}
+			literal, err := formatLiteral(style, args[n-1])
+			if err != nil {
+				return ``, err
+			}
+			builder.WriteString(literal)
+			used[n-1] = true
+			i = end - 1
+		default:
+			builder.WriteByte(c)
+		}
+	}
+
+	// the args not inlined mean the placeholders are not recognized
+	for n, ok := range used {
+		if !ok {
+			return ``, fmt.Errorf(`gobatis: Interpolate: no placeholder for arg %d`, n+1)
+		}
+	}
+	return builder.String(), nil
+}
+
+// formatLiteral format the value as sql literal
+func formatLiteral(style int, v any) (string, error) {
+	if _, ok := v.(SecretValue); ok {
+		return quoteString(style, SecretMask), nil
+	}
+	if valuer, ok := v.(driver.Valuer); ok {
+		if rv := reflect.ValueOf(v); rv.Kind() == reflect.Ptr && rv.IsNil() {
+			return `NULL`, nil
+		}
+		value, err := valuer.Value()
+		if err != nil {
+			return ``, err
+		}
+		return formatLiteral(style, value)
+	}
+
+	switch v := v.(type) {
+	case nil:
+		return `NULL`, nil
+	case string:
+		return quoteString(style, v), nil
+	case []byte:
+		if v == nil {
+			return `NULL`, nil
+		}
+		return quoteBytes(style, v), nil
+	case time.Time:
+		return quoteTime(style, v), nil
+	case DateTime:
+		return quoteTime(style, v.ToTime()), nil
+	case bool:
+		switch style {
+		case literalPostgres, literalMysql:
+			return strings.ToUpper(strconv.FormatBool(v)), nil
+		default:
+			if v {
+				return `1`, nil
+			}
+			return `0`, nil
+		}
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Ptr:
+		if rv.IsNil() {
+			return `NULL`, nil
+		}
+		return formatLiteral(style, rv.Elem().Interface())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(rv.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(rv.Uint(), 10), nil
+	case reflect.Float32:
+		return strconv.FormatFloat(rv.Float(), 'g', -1, 32), nil
+	case reflect.Float64:
+		return strconv.FormatFloat(rv.Float(), 'g', -1, 64), nil
+	case reflect.String:
+		return quoteString(style, rv.String()), nil
+	case reflect.Bool:
+		return formatLiteral(style, rv.Bool())
+	default:
+		return quoteString(style, fmt.Sprintf(`%v`, v)), nil
+	}
+}
+
+func quoteString(style int, v string) string {
+	switch style {
+	case literalMysql:
+		return `'` + strings.NewReplacer(
+			`\`, `\\`,
+			`'`, `\'`,
+			"\x00", `\0`,
+			"\n", `\n`,
+			"\r", `\r`,
+			"\x1a", `\Z`,
+		).Replace(v) + `'`
+	case literalSqlserver:
+		return `N'` + strings.ReplaceAll(v, `'`, `''`) + `'`
+	default:
+		return `'` + strings.ReplaceAll(v, `'`, `''`) + `'`
+	}
+}
+
+func quoteBytes(style int, v []byte) string {
+	switch style {
+	case literalPostgres:
+		return `'\x` + hex.EncodeToString(v) + `'::bytea`
+	case literalSqlserver:
+		return `0x` + hex.EncodeToString(v)
+	case literalOracle:
+		return `HEXTORAW('` + hex.EncodeToString(v) + `')`
+	default:
+		return `X'` + hex.EncodeToString(v) + `'`
+	}
+}
+
+func quoteTime(style int, v time.Time) string {
+	switch style {
+	case literalPostgres:
+		return `'` + v.Format(`2006-01-02 15:04:05.999999999Z07:00`) + `'::timestamptz`
+	case literalMysql:
+		return `'` + v.Format(`2006-01-02 15:04:05.999999`) + `'`
+	case literalSqlserver:
+		return `'` + v.Format(`2006-01-02T15:04:05.9999999Z07:00`) + `'`
+	case literalOracle:
+		return `TIMESTAMP '` + v.Format(`2006-01-02 15:04:05.999999999 -07:00`) + `'`
+	default:
+		return `'` + v.Format(`2006-01-02 15:04:05.999999999-07:00`) + `'`
+	}
+}
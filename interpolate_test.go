@@ -0,0 +1,80 @@
+package gobatis
+
+import (
+	"testing"
+	"time"
+)
+
+func TestInterpolate(t *testing.T) {
+	at := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		typ       string
+		statement string
+		args      []any
+		want      string
+	}{
+		{`postgres`, `postgres`, `select * from t where id = $1 and name = $2`, []any{1, `o'neil`},
+			`select * from t where id = 1 and name = 'o''neil'`},
+		{`postgres reorder`, `postgres`, `select $2, $1, $2`, []any{`a`, true},
+			`select TRUE, 'a', TRUE`},
+		{`postgres literal types`, `postgres`, `insert into t values ($1, $2, $3)`, []any{nil, []byte{0xca, 0xfe}, at},
+			`insert into t values (NULL, '\xcafe'::bytea, '2024-01-02 03:04:05Z'::timestamptz)`},
+		{`postgres quoted`, `postgres`, `select '$1', "$1", $1`, []any{2},
+			`select '$1', "$1", 2`},
+		{`mysql`, `mysql`, `select * from t where name = ? and ok = ?`, []any{"it's\n", false},
+			`select * from t where name = 'it\'s\n' and ok = FALSE`},
+		{`sqlite`, `sqlite3`, `select ?, ?`, []any{true, []byte{1}},
+			`select 1, X'01'`},
+		{`unknown driver`, `unknown`, `select ?`, []any{`a\'b`},
+			`select 'a\''b'`},
+		{`standard E string`, `sqlite3`, `select E'a\', ?`, []any{1},
+			`select E'a\', 1`},
+		{`sqlserver`, `sqlserver`, `select @p1, @p2`, []any{`名字`, 1.5},
+			`select N'名字', 1.5`},
+		{`oracle`, `godror`, `select :1 from dual`, []any{int64(7)},
+			`select 7 from dual`},
+		{`comments`, `postgres`, "select $1 -- $2\n/* $2 */ from t", []any{1},
+			"select 1 -- $2\n/* $2 */ from t"},
+		{`mysql escaped quote`, `mysql`, `select 'it\'s ?', ?`, []any{1},
+			`select 'it\'s ?', 1`},
+		{`postgres doubled quote`, `postgres`, `select 'it''s $1', $1`, []any{1},
+			`select 'it''s $1', 1`},
+		{`pointer`, `postgres`, `select $1, $2`, []any{(*int)(nil), &at},
+			`select NULL, '2024-01-02 03:04:05Z'::timestamptz`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Interpolate(tt.typ, tt.statement, tt.args...)
+			if err != nil {
+				t.Fatalf(`Interpolate() error = %v`, err)
+			}
+			if got != tt.want {
+				t.Errorf("Interpolate()\n got: %s\nwant: %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInterpolateErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		typ       string
+		statement string
+		args      []any
+	}{
+		{`missing arg`, `mysql`, `select ?, ?`, []any{1}},
+		{`missing numbered arg`, `postgres`, `select $2`, []any{1}},
+		{`unused arg`, `mysql`, `select ?`, []any{1, 2}},
+		{`unused numbered arg`, `postgres`, `select $1, $3`, []any{1, 2, 3}},
+		{`placeholder in comment`, `mysql`, `select 1 -- ?`, []any{1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, err := Interpolate(tt.typ, tt.statement, tt.args...); err == nil {
+				t.Errorf(`Interpolate() = %q, want error`, got)
+			}
+		})
+	}
+}
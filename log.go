@@ -0,0 +1,102 @@
+package gobatis
+
+import (
+	"context"
+	"log"
+	"math/rand"
+	"time"
+)
+
+// LogEvent the statement sent to StructuredLogger
+type LogEvent struct {
+	// Level LogLevelDebug, LogLevelInfo or LogLevelError
+	Level    int
+	Duration time.Duration
+	MapperID string
+	// Type StatementSelect, StatementInsert, StatementUpdate, StatementDelete or StatementRaw
+	Type string
+	// SQL and Args the prepared statement, the sensitive args are masked
+	SQL  string
+	Args []any
+	// Rows rows returned by Find, or rows affected by Execute and RawExec
+	Rows int64
+	// InTransaction the statement is executed in transaction
+	InTransaction bool
+	Err           error
+}
+
+// StructuredLogger receive the statements as events, see WithStructuredLogger
+type StructuredLogger interface {
+	LogEvent(ctx context.Context, event *LogEvent)
+}
+
+// newLogEvent create event of the current statement
+func (b *DB) newLogEvent(level int, err error, statements string, args []any) *LogEvent {
+	return &LogEvent{
+		Level:         level,
+		Duration:      time.Since(b.startTime),
+		MapperID:      b.mapperId,
+		Type:          b.statementType(),
+		SQL:           statements,
+		Args:          args,
+		Rows:          b.RowsAffected,
+		InTransaction: b.tx != nil,
+		Err:           err,
+	}
+}
+
+// log send statements to loggers if set, the sensitive args should be masked before.
+func (b *DB) log(level int, err error, statements string, args ...any) {
+	if b.logger == nil && b.structuredLogger == nil {
+		return
+	}
+	b.emit(b.newLogEvent(level, err, statements, args))
+}
+
+// emit send the event to loggers, the debug events are sampled
+// Logger receives the error as args[0] if no statements.
+func (b *DB) emit(event *LogEvent) {
+	if event.Level == LogLevelDebug && b.debugSampling < 1 && rand.Float64() >= b.debugSampling {
+		return
+	}
+	if b.logger != nil {
+		if event.SQL == `` && event.Err != nil {
+			b.logger.Log(b.ctx, event.Level, event.Duration.Nanoseconds(), ``, event.Err)
+		} else {
+			b.logger.Log(b.ctx, event.Level, event.Duration.Nanoseconds(), event.SQL, event.Args...)
+		}
+	}
+	if b.structuredLogger != nil {
+		b.structuredLogger.LogEvent(b.ctx, event)
+	}
+}
+
+// StdLogger StructuredLogger writes to the standard log.Logger
+type StdLogger struct {
+	logger *log.Logger
+}
+
+// NewStdLogger create StructuredLogger writes to logger, log.Default() if nil
+func NewStdLogger(logger *log.Logger) *StdLogger {
+	if logger == nil {
+		logger = log.Default()
+	}
+	return &StdLogger{logger: logger}
+}
+
+func (l *StdLogger) LogEvent(ctx context.Context, event *LogEvent) {
+	level := `DEBUG`
+	switch event.Level {
+	case LogLevelInfo:
+		level = `INFO`
+	case LogLevelError:
+		level = `ERROR`
+	}
+	if event.Err != nil {
+		l.logger.Printf(`gobatis %s mapper=%s type=%s tx=%t rows=%d duration=%s sql=%q args=%v error=%q`,
+			level, event.MapperID, event.Type, event.InTransaction, event.Rows, event.Duration, event.SQL, event.Args, event.Err)
+		return
+	}
+	l.logger.Printf(`gobatis %s mapper=%s type=%s tx=%t rows=%d duration=%s sql=%q args=%v`,
+		level, event.MapperID, event.Type, event.InTransaction, event.Rows, event.Duration, event.SQL, event.Args)
+}
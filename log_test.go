@@ -0,0 +1,92 @@
+package gobatis
+
+import (
+	"bytes"
+	"context"
+	"database/sql/driver"
+	"errors"
+	"log"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// testEventLogger record the log events
+type testEventLogger struct {
+	mu     sync.Mutex
+	events []*LogEvent
+}
+
+func (l *testEventLogger) LogEvent(ctx context.Context, event *LogEvent) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.events = append(l.events, event)
+}
+
+const logMapper = `<mapper>
+<select id="find" sensitive="password">select * from users where password = #{password}</select>
+<update id="update">update users set name = #{name}</update>
+</mapper>`
+
+func TestStructuredLogger(t *testing.T) {
+	events := &testEventLogger{}
+	db, server := openTest(t, logMapper, WithStructuredLogger(events))
+	server.setRows([]string{`id`}, []driver.Value{int64(1)}, []driver.Value{int64(2)})
+
+	var users []scanUser
+	if err := db.Mapper(`find`).Args(Args{`password`: `hunter2`}).Find(&users).Error; err != nil {
+		t.Fatalf(`Find() error = %v`, err)
+	}
+	cause := errors.New(`boom`)
+	err := db.Transaction(func(tx *DB) error {
+		if err := tx.Mapper(`update`).Args(Args{`name`: `a`}).Execute().Error; err != nil {
+			return err
+		}
+		server.setErr(cause)
+		return tx.Mapper(`update`).Args(Args{`name`: `b`}).Execute().Error
+	})
+	if !errors.Is(err, cause) {
+		t.Fatalf(`Transaction() error = %v`, err)
+	}
+
+	if len(events.events) != 3 {
+		t.Fatalf(`%d events, want 3`, len(events.events))
+	}
+	find, update, failed := events.events[0], events.events[1], events.events[2]
+	if find.Level != LogLevelDebug || find.MapperID != `find` || find.Type != StatementSelect || find.Rows != 2 ||
+		find.InTransaction || find.Err != nil || find.SQL != `select * from users where password = ?` {
+		t.Errorf(`find event = %+v`, find)
+	}
+	if !reflect.DeepEqual(find.Args, []any{Secret(`hunter2`)}) {
+		t.Errorf(`find event args = %v, want masked`, find.Args)
+	}
+	if update.Type != StatementUpdate || update.Rows != 1 || !update.InTransaction || !reflect.DeepEqual(update.Args, []any{`a`}) {
+		t.Errorf(`update event = %+v`, update)
+	}
+	if failed.Level != LogLevelError || !errors.Is(failed.Err, cause) || !reflect.DeepEqual(failed.Args, []any{`b`}) {
+		t.Errorf(`failed event = %+v`, failed)
+	}
+}
+
+func TestStdLogger(t *testing.T) {
+	var out bytes.Buffer
+	db, server := openTest(t, logMapper, WithStructuredLogger(NewStdLogger(log.New(&out, ``, 0))))
+
+	db.Mapper(`update`).Args(Args{`name`: `a`}).Execute()
+	server.setErr(errors.New(`boom`))
+	db.Mapper(`update`).Args(Args{`name`: `b`}).Execute()
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("logs\n%s", out.String())
+	}
+	if !strings.HasPrefix(lines[0], `gobatis DEBUG mapper=update type=update tx=false rows=1 duration=`) ||
+		!strings.HasSuffix(lines[0], `sql="update users set name = ?" args=[a]`) {
+		t.Errorf(`debug log = %q`, lines[0])
+	}
+	if !strings.HasPrefix(lines[1], `gobatis ERROR mapper=update type=update`) ||
+		!strings.HasSuffix(lines[1], `args=[b] error="gobatis: mapper update: exec: boom"`) {
+		t.Errorf(`error log = %q`, lines[1])
+	}
+}
@@ -8,11 +8,10 @@ import (
 	"errors"
 	"fmt"
 	"reflect"
-	"regexp"
 	"strings"
-	"sync"
 
 	"github.com/fbatis/expr"
+	"github.com/fbatis/expr/vm"
 
 	"github.com/google/uuid"
 )
@@ -25,36 +24,23 @@ var (
 	ErrorOtherwiseMustFollowChooseStmt   = errors.New(`otherwise must follow when statement`)
 	ErrorForeachNeedCollection           = errors.New(`foreach statment need collection attr`)
 	ErrorForeachNeedItem                 = errors.New(`foreach statment need item attr`)
-	ErrorInputMustBeMap                  = errors.New(`input must be map`)
+	ErrorInputMustBeMap                  = errors.New(`input must be map or struct`)
 	ErrorForeachStatementIsNotArrayOrMap = errors.New(`foreach statement is not array or map`)
 	ErrorIncludeTagNeedRefIdAttr         = errors.New(`include tag need refid attr`)
-
-	variable   *regexp.Regexp
-	multiSpace *regexp.Regexp
 )
 
-func init() {
-	var err error
-
-	// exp variables
-	variable, err = regexp.Compile(`([#$]\{.*?})`)
-	if err != nil {
-		panic(err)
-	}
-
-	// multiSpaceExp variables
-	multiSpace, err = regexp.Compile(" +")
-	if err != nil {
-		panic(err)
-	}
-}
-
 type HandlerPayload struct {
 	Input     any
-	SqlMapper map[string]string
+	SqlMapper map[string]*Sql
 
 	fromChoose bool
-	uuidMap    sync.Map
+	sensitive  map[string]bool
+	noBeautify bool
+
+	// scope variables visible to the expressions, copied from Input
+	scope map[string]any
+	// env functions and constants merged into scope, the variables of Input take precedence
+	env map[string]any
 }
 
 // NewUuid generate uuid for variables
@@ -63,12 +49,14 @@ func NewUuid() string {
 }
 
 type Handler interface {
-	Evaluate(ctx context.Context, input *HandlerPayload) (string, error)
+	Evaluate(ctx context.Context, input *HandlerPayload) (*Fragments, error)
 }
 
 type BindVar struct {
 	stateSql string
 	args     []interface{}
+	masks    []bool
+	typ      string
 	err      error
 }
 
@@ -80,99 +68,117 @@ type BindHandler interface {
 	Bind(ctx context.Context, input *HandlerPayload) *BindVar
 }
 
-func placeHolder(typ string, count int) string {
-	switch strings.ToLower(typ) {
-	case `postgres`, `pg`, `pgx`, `pgx/v5`: // postgres use $1, $2, ... as placeholders for prepare statements.
-		return fmt.Sprintf(`$%d`, count+1)
-	case `sqlserver`, `mssql`:
-		return fmt.Sprintf(`@p%d`, count+1) // for sqlserver, use @p1, @p2, ... as placeholders for prepare statements.
-	case `godror`, `goracle`:
-		return fmt.Sprintf(`:%d`, count+1) // for oracle, use :1, :2, ... as placeholders for prepare statements.
-	default: // mysql or sqlite use ? as placeholders for prepare statements.
-		return `?`
+func bindParamsToVar(ctx context.Context, m Handler, attrMap map[string]string, input *HandlerPayload) *BindVar {
+	scope, err := newScope(input.Input)
+	if err != nil {
+		return &BindVar{err: err}
 	}
-}
+	typeValue, _ := attrMap[TypeKey]
+	dialect := dialectOf(typeValue)
 
-func bindParamsToVar(ctx context.Context, m Handler, attrMap map[string]string, input *HandlerPayload) *BindVar {
-	if reflect.TypeOf(input.Input).Kind() == reflect.Ptr {
-		input.Input = reflect.ValueOf(input.Input).Elem().Interface()
+	mergeScope(scope, input.env)
+	if _, ok := scope[ctxVariable]; !ok {
+		scope[ctxVariable] = ctx
+	}
+	if _, ok := scope[dialectVariable]; !ok {
+		scope[dialectVariable] = exprDialect{dialect}
 	}
+	input.scope = scope
+	input.sensitive = sensitiveNames(attrMap, input.sensitive)
 
-	prepareStmt, err := m.Evaluate(ctx, input)
+	fragments, err := m.Evaluate(ctx, input)
 	if err != nil {
 		return &BindVar{err: err}
 	}
 
-	matches := variable.FindAllString(prepareStmt, -1)
-	args := make([]interface{}, 0, len(matches))
-	typeValue, _ := attrMap[TypeKey]
+	args := make([]interface{}, 0, len(fragments.parts))
 
-	for i, match := range matches {
-		matchKey := strings.Trim(match, `$#{}`)
-		matchValue, err := expr.Eval(matchKey, input.Input)
-		if err != nil {
-			return &BindVar{err: err}
+	var masks []bool
+	mask := func(secret bool) {
+		if secret && masks == nil {
+			masks = make([]bool, len(args), cap(args))
 		}
-		if matchValue != nil && reflect.TypeOf(matchValue).Kind() == reflect.String &&
-			strings.Contains(matchValue.(string), `<nil>`) {
-			input.uuidMap.Range(func(key, value any) bool {
-				if strings.Contains(matchKey, key.(string)) {
-					matchKey = strings.ReplaceAll(matchKey, key.(string), value.(string))
-				}
-				return true
-			})
-			return &BindVar{err: fmt.Errorf("gobatis: Args not define: %s variable", matchKey)}
+		if masks != nil {
+			masks = append(masks, secret)
+		}
+	}
+
+	// assign the placeholders and args in one pass
+	var builder strings.Builder
+	builder.Grow(len(fragments.parts) * 8)
+	for _, part := range fragments.parts {
+		if !part.slot {
+			builder.WriteString(part.text)
+			continue
 		}
-		if strings.HasPrefix(match, `#`) {
-			mv := reflect.ValueOf(matchValue)
-			for mv.Kind() == reflect.Ptr {
-				mv = mv.Elem()
-			}
-
-			var holders string
-			_, driverInterface := matchValue.(interface {
-				sql.Scanner
-				driver.Valuer
-			})
-
-			if (mv.Kind() == reflect.Slice || mv.Kind() == reflect.Array) && !driverInterface {
-				var holderArr = make([]string, 0, mv.Len())
-				for j := 0; j < mv.Len(); j++ {
-					holders := placeHolder(typeValue, i+j)
-					holderArr = append(holderArr, holders)
-					args = append(args, mv.Index(j).Interface())
+
+		matchValue, secret := part.value, part.secret
+		mv := reflect.ValueOf(matchValue)
+		for mv.Kind() == reflect.Ptr {
+			mv = mv.Elem()
+		}
+
+		_, driverInterface := matchValue.(interface {
+			sql.Scanner
+			driver.Valuer
+		})
+
+		if (mv.Kind() == reflect.Slice || mv.Kind() == reflect.Array) && !driverInterface {
+			for j := 0; j < mv.Len(); j++ {
+				if j > 0 {
+					builder.WriteString(`, `)
 				}
-				holders = strings.Join(holderArr, `, `)
-			} else {
-				args = append(args, matchValue)
-				holders = placeHolder(typeValue, i)
+				builder.WriteString(dialect.Placeholder(len(args) + 1))
+				mask(secret)
+				args = append(args, mv.Index(j).Interface())
 			}
-
-			prepareStmt = strings.Replace(prepareStmt, match, holders, 1)
-		} else if strings.HasPrefix(match, `$`) {
-			prepareStmt = strings.ReplaceAll(prepareStmt, match, fmt.Sprintf(`%v`, matchValue))
+		} else {
+			builder.WriteString(dialect.Placeholder(len(args) + 1))
+			mask(secret)
+			args = append(args, matchValue)
 		}
 	}
+	prepareStmt := builder.String()
 
-	// Beautify the sql
-	// if you don't need sql beautify, you can comment below two lines
-	prepareStmt = strings.ReplaceAll(prepareStmt, "\n", ``)
-	prepareStmt = string(multiSpace.ReplaceAll([]byte(prepareStmt), []byte{' '}))
+	// Beautify the sql, disabled by WithoutBeautify
+	if !input.noBeautify {
+		prepareStmt = beautifySQL(typeValue, prepareStmt)
+	}
 
 	return &BindVar{
 		stateSql: strings.TrimSpace(prepareStmt),
 		args:     args,
+		masks:    masks,
+		typ:      typeValue,
 		err:      nil,
 	}
 }
 
-// exprEvaluate statement and get true or false
-func exprEvaluate(exprString string, input interface{}) (bool, error) {
-	prog, err := expr.Compile(exprString)
+// compileAttr compile the expression of attribute key, return nil if attribute not set
+func compileAttr(attrMap map[string]string, key string) (*vm.Program, error) {
+	exprString, ok := attrMap[key]
+	if !ok || exprString == `` {
+		return nil, nil
+	}
+	program, err := expr.Compile(exprString)
 	if err != nil {
-		return false, err
+		return nil, fmt.Errorf(`gobatis: compile %s="%s": %w`, key, exprString, err)
+	}
+	return program, nil
+}
+
+// programOf return the program compiled when the xml is parsed, the elements built in code,
+// forexample the mappers passed to WithMapper, are compiled on every evaluation.
+func programOf(program *vm.Program, attrMap map[string]string, key string) (*vm.Program, error) {
+	if program != nil {
+		return program, nil
 	}
-	output, err := expr.Run(prog, input)
+	return compileAttr(attrMap, key)
+}
+
+// exprEvaluate run the compiled program and get true or false
+func exprEvaluate(program *vm.Program, scope map[string]any) (bool, error) {
+	output, err := runExpr(program, scope)
 	if err != nil {
 		return false, err
 	}
@@ -186,70 +192,77 @@ func exprEvaluate(exprString string, input interface{}) (bool, error) {
 	}
 }
 
-// intervalEvaluate used for caculate the xml chardata if condition ok.
-func intervalEvaluate(ctx context.Context, children []interface{}, input *HandlerPayload) (string, error) {
-	var builder strings.Builder
-	builder.Grow(128)
-
+// intervalEvaluate used for caculate the xml chardata if condition ok,
+// the children are rendered into out.
+func intervalEvaluate(ctx context.Context, children []interface{}, input *HandlerPayload, out *Fragments) error {
 	var ok bool
-	var err error
 	var ifExist bool
 	var whenExist bool
 
+	// render the children surrounded by spaces
+	render := func(children []interface{}) error {
+		out.WriteString(` `)
+		if err := intervalEvaluate(ctx, children, input, out); err != nil {
+			return err
+		}
+		out.WriteString(` `)
+		return nil
+	}
+
 	for _, child := range children {
 	redo:
 		switch v := child.(type) {
 		case *If:
 			ifExist = true
-			if testNode, exist := v.AttrsMap[TestKey]; exist && testNode != `` {
-				if ok, err = exprEvaluate(testNode, input.Input); err != nil {
-					return ``, err
+			program, err := programOf(v.program, v.AttrsMap, TestKey)
+			if err != nil {
+				return err
+			}
+			if program != nil {
+				if ok, err = exprEvaluate(program, input.scope); err != nil {
+					return err
 				} else if ok {
-					if innerText, err := intervalEvaluate(ctx, v.Children, input); err != nil {
-						return ``, err
-					} else {
-						builder.WriteString(` ` + innerText + ` `)
+					if err := render(v.Children); err != nil {
+						return err
 					}
 				}
 			}
 		case *Elif:
 			if !ifExist {
-				return ``, ErrorElifMustFollowIfStmt
+				return ErrorElifMustFollowIfStmt
 			}
 
 			if ok {
 				continue
 			}
-			if testNode, exist := v.AttrsMap[TestKey]; exist && testNode != `` {
-				if ok, err = exprEvaluate(testNode, input.Input); err != nil {
-					return ``, err
+			program, err := programOf(v.program, v.AttrsMap, TestKey)
+			if err != nil {
+				return err
+			}
+			if program != nil {
+				if ok, err = exprEvaluate(program, input.scope); err != nil {
+					return err
 				} else if ok {
-					if innerText, err := intervalEvaluate(ctx, v.Children, input); err != nil {
-						return ``, err
-					} else {
-						builder.WriteString(` ` + innerText + ` `)
+					if err := render(v.Children); err != nil {
+						return err
 					}
 				}
 			}
 		case *Else:
 			if !ifExist {
-				return ``, ErrorElseMustFollowIfStmt
+				return ErrorElseMustFollowIfStmt
 			}
 
 			if ok {
 				continue
 			}
-			if innerText, err := intervalEvaluate(ctx, v.Children, input); err != nil {
-				return ``, err
-			} else {
-				builder.WriteString(` ` + innerText + ` `)
+			if err := render(v.Children); err != nil {
+				return err
 			}
 		case *Choose:
 			input.fromChoose = true
-			if innerText, err := intervalEvaluate(ctx, v.Children, input); err != nil {
-				return ``, err
-			} else {
-				builder.WriteString(` ` + innerText + ` `)
+			if err := render(v.Children); err != nil {
+				return err
 			}
 			input.fromChoose = false
 		case *When:
@@ -257,196 +270,171 @@ func intervalEvaluate(ctx context.Context, children []interface{}, input *Handle
 				continue
 			}
 			whenExist = true
-			if testNode, exist := v.AttrsMap[TestKey]; exist && testNode != `` {
-				if ok, err = exprEvaluate(testNode, input.Input); err != nil {
-					return ``, err
+			program, err := programOf(v.program, v.AttrsMap, TestKey)
+			if err != nil {
+				return err
+			}
+			if program != nil {
+				if ok, err = exprEvaluate(program, input.scope); err != nil {
+					return err
 				} else if ok {
-					if innerText, err := intervalEvaluate(ctx, v.Children, input); err != nil {
-						return ``, err
-					} else {
-						builder.WriteString(` ` + innerText + ` `)
+					if err := render(v.Children); err != nil {
+						return err
 					}
 				}
 			}
 		case *Otherwise:
 			if !whenExist {
-				return ``, ErrorOtherwiseMustFollowChooseStmt
+				return ErrorOtherwiseMustFollowChooseStmt
 			}
 			if ok {
 				continue
 			}
-			if innerText, err := intervalEvaluate(ctx, v.Children, input); err != nil {
-				return ``, err
-			} else {
-				builder.WriteString(` ` + innerText + ` `)
+			if err := render(v.Children); err != nil {
+				return err
 			}
 		case *Foreach:
-			var collection string
 			var item string
 			var separator string
-			var arrayIndexKey string
 
-			if collection, ok = v.AttrsMap[CollectionKey]; !ok {
-				return ``, ErrorForeachNeedCollection
+			program, err := programOf(v.program, v.AttrsMap, CollectionKey)
+			if err != nil {
+				return err
+			}
+			if program == nil {
+				return ErrorForeachNeedCollection
 			}
 			if item, ok = v.AttrsMap[ItemKey]; !ok {
-				return ``, ErrorForeachNeedItem
+				return ErrorForeachNeedItem
 			}
 			separator, _ = v.AttrsMap[SeparatorKey]
 			arrayIndex, _ := v.AttrsMap[IndexKey]
 			arrayIndex = strings.TrimSpace(arrayIndex)
 
-			value, err := expr.Eval(collection, input.Input)
+			value, err := runExpr(program, input.scope)
 			if err != nil {
-				return ``, err
-			}
-			t := reflect.TypeOf(value)
-			if t == nil {
-				continue
+				return err
 			}
-
-			for t.Kind() == reflect.Ptr {
-				t = t.Elem()
-			}
-
 			val := reflect.ValueOf(value)
-
-			if reflect.TypeOf(input.Input).Kind() != reflect.Map {
-				return ``, ErrorInputMustBeMap
+			for val.Kind() == reflect.Ptr {
+				val = val.Elem()
 			}
-			inputMap := reflect.ValueOf(input.Input)
-			for inputMap.Kind() == reflect.Ptr {
-				inputMap = inputMap.Elem()
+			if !val.IsValid() {
+				continue
 			}
 
-			switch t.Kind() {
+			switch val.Kind() {
 			case reflect.Slice, reflect.Array:
-				var textArr []string
+				// bind item & index in the child scope, the placeholders of children
+				// capture the values of current loop when evaluated.
+				parent := input.scope
+				input.scope = childScope(parent, 2)
+
+				out.WriteString(` `)
 				for i := 0; i < val.Len(); i++ {
-					collectionKey := fmt.Sprintf(`%s[%d]`, collection, i)
-					sliceItem, err := expr.Eval(collectionKey, input.Input)
-					if err != nil {
-						return ``, err
+					if i > 0 {
+						out.WriteString(separator)
 					}
-					// Save the original value of the item key
-					itemValue := reflect.ValueOf(item)
-					previousItemValue := inputMap.MapIndex(itemValue)
-					// Save the original value of the index key
-					arrayIndexValue := reflect.ValueOf(arrayIndex)
-					previousArrayIndexValue := inputMap.MapIndex(arrayIndexValue)
-
-					inputMap.SetMapIndex(itemValue, reflect.ValueOf(sliceItem))
+					input.scope[item] = val.Index(i).Interface()
 					if arrayIndex != `` {
-						arrayIndexKey = NewUuid()
-						inputMap.SetMapIndex(reflect.ValueOf(arrayIndexKey), reflect.ValueOf(i))
-						inputMap.SetMapIndex(arrayIndexValue, reflect.ValueOf(i))
-						input.uuidMap.Store(arrayIndexKey, arrayIndex)
+						input.scope[arrayIndex] = i
 					}
-					var newText string
-					if newText, err = intervalEvaluate(ctx, v.Children, &HandlerPayload{
-						Input:      inputMap.Interface(),
-						SqlMapper:  input.SqlMapper,
-						fromChoose: input.fromChoose,
-					}); err != nil {
-						return ``, err
+					if err := intervalEvaluate(ctx, v.Children, input, out); err != nil {
+						input.scope = parent
+						return err
 					}
-
-					// restore the item & index value if in original input value
-					inputMap.SetMapIndex(itemValue, previousItemValue)
-					if arrayIndex != `` {
-						// restore array index value from original input value
-						inputMap.SetMapIndex(arrayIndexValue, previousArrayIndexValue)
-						matches := variable.FindAllString(newText, -1)
-						for _, match := range matches {
-							newText = strings.NewReplacer(match, strings.NewReplacer(
-								arrayIndex,
-								arrayIndexKey,
-							).Replace(match)).Replace(newText)
-						}
-					}
-					matches := variable.FindAllString(newText, -1)
-					for _, match := range matches {
-						newText = strings.NewReplacer(
-							match,
-							strings.NewReplacer(
-								item,
-								collectionKey,
-							).Replace(match),
-						).Replace(newText)
-						input.uuidMap.Store(collectionKey, item)
-					}
-					textArr = append(textArr, newText)
 				}
-				builder.WriteString(` ` + strings.Join(textArr, separator) + ` `)
+				out.WriteString(` `)
+
+				input.scope = parent
 			default:
-				return ``, ErrorForeachStatementIsNotArrayOrMap
+				return ErrorForeachStatementIsNotArrayOrMap
 			}
 		case *Trim:
-			if innerText, err := intervalEvaluate(ctx, v.Children, input); err != nil {
-				return ``, err
-			} else {
-				innerText = strings.TrimSpace(innerText)
-
-				if prefixOverrides, ok := v.AttrsMap[PrefixOverridesKey]; ok {
-					for _, prefix := range strings.Split(prefixOverrides, `|`) {
-						prefix := strings.ToLower(strings.TrimSpace(prefix))
-						innerTextLower := strings.ToLower(innerText)
-						if strings.HasPrefix(innerTextLower, prefix) {
-							innerText = innerText[len(prefix):]
-						}
-					}
-				}
-				if prefix, ok := v.AttrsMap[PrefixKey]; ok && prefix != `` {
-					builder.WriteString(prefix + ` `)
-				}
+			var inner Fragments
+			if err := intervalEvaluate(ctx, v.Children, input, &inner); err != nil {
+				return err
+			}
+			inner.trimSpace()
 
-				builder.WriteString(` ` + innerText + ` `)
+			if prefixOverrides, ok := v.AttrsMap[PrefixOverridesKey]; ok {
+				for _, prefix := range strings.Split(prefixOverrides, `|`) {
+					inner.trimPrefix(strings.ToLower(strings.TrimSpace(prefix)))
+				}
+			}
+			if prefix, ok := v.AttrsMap[PrefixKey]; ok && prefix != `` {
+				out.WriteString(prefix + ` `)
 			}
+
+			out.WriteString(` `)
+			out.append(&inner)
+			out.WriteString(` `)
 		case *Include:
 			if v.RefId == `` {
-				return ``, ErrorIncludeTagNeedRefIdAttr
+				return ErrorIncludeTagNeedRefIdAttr
 			}
 			if sqlMapper, ok := input.SqlMapper[v.RefId]; ok {
-				sqlMapper = strings.NewReplacer(
-					fmt.Sprintf(`${%s}`, v.Alias),
-					v.Value,
-				).Replace(sqlMapper)
-				builder.WriteString(` ` + sqlMapper + ` `)
+				out.WriteString(` `)
+				segments, err := sqlMapper.textSegments()
+				if err != nil {
+					return err
+				}
+				if err := evaluateSegments(out, segments, input, map[string]string{v.Alias: v.Value}); err != nil {
+					return err
+				}
+				out.WriteString(` `)
 			} else {
-				return ``, fmt.Errorf(`mapper: sql mapper with id: %s not found`, v.RefId)
+				return fmt.Errorf(`mapper: sql mapper with id: %s not found`, v.RefId)
 			}
 		case *Where:
-			if innerText, err := intervalEvaluate(ctx, v.Children, input); err != nil {
-				return ``, err
-			} else {
-				innerText = strings.TrimSpace(innerText)
-				innerTextLower := strings.ToLower(innerText)
-				if strings.HasPrefix(innerTextLower, `and`) {
-					innerText = innerText[3:]
-				}
-				if strings.HasPrefix(innerTextLower, `or`) {
-					innerText = innerText[2:]
-				}
-				if strings.TrimSpace(innerText) != `` {
-					builder.WriteString(` WHERE ` + innerText + ` `)
-				}
+			var inner Fragments
+			if err := intervalEvaluate(ctx, v.Children, input, &inner); err != nil {
+				return err
+			}
+			inner.trimSpace()
+			if !inner.trimPrefix(`and`) {
+				inner.trimPrefix(`or`)
+			}
+			if !inner.empty() {
+				out.WriteString(` WHERE `)
+				out.append(&inner)
+				out.WriteString(` `)
 			}
 		case *Sql:
-			input.SqlMapper[v.Id] = v.Text
+			// the nested sql is visible to the rest of the statement, the shared mappers are not modified
+			if input.SqlMapper[v.Id] != v {
+				sqlMapper := make(map[string]*Sql, len(input.SqlMapper)+1)
+				for id, sql := range input.SqlMapper {
+					sqlMapper[id] = sql
+				}
+				sqlMapper[v.Id] = v
+				input.SqlMapper = sqlMapper
+			}
 		case *interface{}:
 			if child == nil {
 				continue
 			}
 			child = *v
 			goto redo
+		case *Text:
+			if err := v.Evaluate(out, input); err != nil {
+				return err
+			}
 		case xml.CharData:
-			builder.Write(v)
+			// the chardata of the mappers built in code
+			segments, err := parseTextSegments(string(v))
+			if err != nil {
+				return err
+			}
+			if err := evaluateSegments(out, segments, input, nil); err != nil {
+				return err
+			}
 		default:
-			return ``, ErrorElementNotSupported
+			return ErrorElementNotSupported
 		}
 	}
 
-	return builder.String(), nil
+	return nil
 }
 
 // Mapper all data mapper into Mapper struct
@@ -0,0 +1,136 @@
+package gobatis
+
+import (
+	"container/list"
+	"context"
+	"encoding/xml"
+	"sync"
+)
+
+// namedTemplateSize max parsed templates of the named raw statements
+const namedTemplateSize = 1024
+
+// namedTemplates parsed templates of the named raw statements keyed by the sql,
+// shared by all the DBs, the least recently used one is dropped when full.
+var namedTemplates = &templateCache{
+	size:    namedTemplateSize,
+	lru:     list.New(),
+	entries: make(map[string]*list.Element, namedTemplateSize),
+}
+
+// templateCache LRU cache of the parsed #{} ${} templates
+type templateCache struct {
+	mu      sync.Mutex
+	size    int
+	lru     *list.List
+	entries map[string]*list.Element
+}
+
+type templateEntry struct {
+	query string
+	text  *Text
+}
+
+// load return the parsed template of query, parse it if not cached
+func (c *templateCache) load(query string) (*Text, error) {
+	c.mu.Lock()
+	if el, ok := c.entries[query]; ok {
+		c.lru.MoveToFront(el)
+		c.mu.Unlock()
+		return el.Value.(*templateEntry).text, nil
+	}
+	c.mu.Unlock()
+
+	// the expressions are compiled outside the lock
+	text, err := NewText(xml.CharData(query))
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.entries[query]; ok {
+		c.lru.MoveToFront(el)
+		return el.Value.(*templateEntry).text, nil
+	}
+	c.entries[query] = c.lru.PushFront(&templateEntry{query: query, text: text})
+	for c.lru.Len() > c.size {
+		entry := c.lru.Remove(c.lru.Back()).(*templateEntry)
+		delete(c.entries, entry.query)
+	}
+	return text, nil
+}
+
+// namedStatement the raw statement with #{} ${} placeholders rendered as the xml mappers
+type namedStatement struct {
+	text     *Text
+	attrsMap map[string]string
+}
+
+func (m *namedStatement) Evaluate(ctx context.Context, input *HandlerPayload) (*Fragments, error) {
+	var out Fragments
+	if err := m.text.Evaluate(&out, input); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (m *namedStatement) Bind(ctx context.Context, input *HandlerPayload) *BindVar {
+	return bindParamsToVar(ctx, m, m.attrsMap, input)
+}
+
+// RawQueryNamed query database with the #{} ${} placeholders bound to args,
+// the slices are expanded and the placeholders are written in the syntax of the driver.
+// then call Find to get result
+//
+// forexample:
+//
+//	db.RawQueryNamed(`select * from users where id in (#{ids}) order by ${order}`, gobatis.Args{...}).Find(&users)
+func (b *DB) RawQueryNamed(query string, args Args) *DB {
+	db, bindVars := b.renderNamed(query, args)
+	if bindVars == nil {
+		return db
+	}
+	return db.openRows(StatementRaw, bindVars)
+}
+
+// RawExecNamed do an insert, update or delete operation with the #{} ${} placeholders bound to args
+func (b *DB) RawExecNamed(query string, args Args) *DB {
+	if b.Error != nil {
+		b.log(LogLevelError, b.Error, ``)
+		return b
+	}
+
+	db, bindVars := b.renderNamed(query, args)
+	if bindVars == nil {
+		return db
+	}
+	end := db.startSpan(StatementRaw)
+	defer func() {
+		db.done(end, db.RowsAffected)
+	}()
+	return db.exec(bindVars)
+}
+
+// renderNamed render query with args on the cloned db, bindVars is nil if failed
+func (b *DB) renderNamed(query string, args Args) (*DB, *BindVar) {
+	db := b.Clone()
+	text, err := namedTemplates.load(query)
+	if err != nil {
+		db.Error = db.wrapError(PhaseBind, err)
+		return db, nil
+	}
+
+	db.mapper = &namedStatement{text: text, attrsMap: map[string]string{TypeKey: b.driverName}}
+	db.mapperType, db.mapperId = mapperSelect, ``
+	if args == nil {
+		args = Args{}
+	}
+	bindVars, err := db.render(args)
+	if err != nil {
+		db.Error = db.wrapError(PhaseBind, err)
+		return db, nil
+	}
+	db.bindVars = bindVars
+	return db, bindVars
+}
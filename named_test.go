@@ -0,0 +1,83 @@
+package gobatis
+
+import (
+	"container/list"
+	"database/sql/driver"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRawNamed(t *testing.T) {
+	var args [][]any
+	db, server := openTest(t, `<mapper></mapper>`, WithInterceptors(InterceptorFunc(func(phase string, inv *Invocation, next func() error) error {
+		if phase == PhaseExec {
+			args = append(args, inv.Args)
+		}
+		return next()
+	})))
+	server.setRows([]string{`id`}, []driver.Value{int64(1)}, []driver.Value{int64(2)})
+
+	var users []scanUser
+	err := db.RawQueryNamed(`select * from users where id in (#{ids}) and name = #{name} order by ${order}`,
+		Args{`ids`: []int{1, 2}, `name`: `a`, `order`: `id desc`}).Find(&users).Error
+	if err != nil {
+		t.Fatalf(`RawQueryNamed() error = %v`, err)
+	}
+	if len(users) != 2 || users[1].Id != 2 {
+		t.Errorf(`Find() = %+v`, users)
+	}
+
+	ndb := db.RawExecNamed(`delete from users where id = #{id}`, Args{`id`: 3})
+	if ndb.Error != nil || ndb.RowsAffected != 1 {
+		t.Fatalf(`RawExecNamed() = %d, %v`, ndb.RowsAffected, ndb.Error)
+	}
+	if err := db.RawExecNamed(`delete from users`, nil).Error; err != nil {
+		t.Fatalf(`RawExecNamed() without args error = %v`, err)
+	}
+
+	want := []string{`select * from users where id in (?, ?) and name = ? order by id desc`, `delete from users where id = ?`, `delete from users`}
+	if got := server.log(); !reflect.DeepEqual(got, want) {
+		t.Errorf("executed\n got: %q\nwant: %q", got, want)
+	}
+	if want := [][]any{{1, 2, `a`}, {3}, {}}; !reflect.DeepEqual(args, want) {
+		t.Errorf(`args = %v, want %v`, args, want)
+	}
+}
+
+func TestRawNamedErrors(t *testing.T) {
+	db, server := openTest(t, `<mapper></mapper>`)
+
+	if err := db.RawExecNamed(`delete from users where id = #{id ==}`, Args{`id`: 1}).Error; err == nil {
+		t.Error(`RawExecNamed() with invalid expression error = nil`)
+	}
+	if err := db.RawQueryNamed(`select * from users order by ${order}`, Args{}).Error; err == nil || !strings.Contains(err.Error(), `order`) {
+		t.Errorf(`RawQueryNamed() without order error = %v`, err)
+	}
+	if statements := server.log(); len(statements) != 0 {
+		t.Errorf(`executed %q`, statements)
+	}
+}
+
+func TestTemplateCache(t *testing.T) {
+	c := &templateCache{size: 2, lru: list.New(), entries: make(map[string]*list.Element)}
+	load := func(query string) *Text {
+		text, err := c.load(query)
+		if err != nil {
+			t.Fatalf(`load(%s) error = %v`, query, err)
+		}
+		return text
+	}
+
+	first := load(`select #{a}`)
+	if load(`select #{a}`) != first {
+		t.Error(`load() parsed the cached query again`)
+	}
+	load(`select #{b}`)
+	load(`select #{a}`)
+	load(`select #{c}`)
+	// select #{b} is the least recently used
+	if _, ok := c.entries[`select #{b}`]; ok || c.lru.Len() != 2 || load(`select #{a}`) != first {
+		t.Errorf(`cached %d templates, select #{b} cached %v`, c.lru.Len(), ok)
+	}
+}
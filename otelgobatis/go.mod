@@ -0,0 +1,20 @@
+module github.com/fbatis/gobatis/otelgobatis
+
+go 1.20
+
+require (
+	github.com/fbatis/gobatis v1.0.3
+	go.opentelemetry.io/otel v1.24.0
+	go.opentelemetry.io/otel/trace v1.24.0
+)
+
+require (
+	github.com/fbatis/expr v1.0.9 // indirect
+	github.com/go-logr/logr v1.4.1 // indirect
+	github.com/go-logr/stdr v1.2.2 // indirect
+	github.com/google/uuid v1.6.0 // indirect
+	go.opentelemetry.io/otel/metric v1.24.0 // indirect
+)
+
+// the adapter requires the Tracer of gobatis not released yet, build it with the gobatis of this repository
+replace github.com/fbatis/gobatis => ../
@@ -0,0 +1,106 @@
+// Package otelgobatis adapts gobatis.Tracer to OpenTelemetry, the spans follow the semantic conventions
+// of database client spans.
+//
+// forexample:
+//
+//	db, err := gobatis.OpenWithEmbedFs(`pgx`, dsn, embedFs, `sql`, gobatis.WithTracer(otelgobatis.NewTracer()))
+package otelgobatis
+
+import (
+	"context"
+	"errors"
+
+	"github.com/fbatis/gobatis"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = `github.com/fbatis/gobatis/otelgobatis`
+
+// attribute keys of the database client spans
+const (
+	dbSystemName        = attribute.Key(`db.system.name`)
+	dbOperationName     = attribute.Key(`db.operation.name`)
+	dbQueryText         = attribute.Key(`db.query.text`)
+	dbResponseRows      = attribute.Key(`db.response.returned_rows`)
+	gobatisMapperID     = attribute.Key(`gobatis.mapper.id`)
+	gobatisRowsAffected = attribute.Key(`gobatis.rows_affected`)
+)
+
+type tracer struct {
+	provider  trace.TracerProvider
+	queryText bool
+	tracer    trace.Tracer
+}
+
+// Option options of NewTracer
+type Option func(*tracer)
+
+// WithTracerProvider use provider instead of the global one
+func WithTracerProvider(provider trace.TracerProvider) Option {
+	return func(t *tracer) {
+		t.provider = provider
+	}
+}
+
+// WithoutQueryText don't record the prepared statements in db.query.text
+func WithoutQueryText() Option {
+	return func(t *tracer) {
+		t.queryText = false
+	}
+}
+
+// NewTracer create gobatis.Tracer starting OpenTelemetry spans
+func NewTracer(opts ...Option) gobatis.Tracer {
+	t := &tracer{provider: otel.GetTracerProvider(), queryText: true}
+	for _, opt := range opts {
+		opt(t)
+	}
+	t.tracer = t.provider.Tracer(instrumentationName)
+	return t
+}
+
+func (t *tracer) StartSpan(ctx context.Context, info *gobatis.SpanInfo) (context.Context, gobatis.SpanEnd) {
+	attrs := []attribute.KeyValue{
+		dbSystemName.String(dbSystem(info)),
+		dbOperationName.String(info.Type),
+	}
+	if info.MapperID != `` {
+		attrs = append(attrs, gobatisMapperID.String(info.MapperID))
+	}
+	if t.queryText && info.SQL != `` {
+		attrs = append(attrs, dbQueryText.String(info.SQL))
+	}
+
+	name := info.Type
+	if info.MapperID != `` {
+		name = info.Type + ` ` + info.MapperID
+	}
+	ctx, span := t.tracer.Start(ctx, name, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(attrs...))
+
+	return ctx, func(err error, rows int64) {
+		switch info.Type {
+		case gobatis.StatementTransaction:
+		case gobatis.StatementSelect:
+			span.SetAttributes(dbResponseRows.Int64(rows))
+		default:
+			span.SetAttributes(gobatisRowsAffected.Int64(rows))
+		}
+		// not found is not the failure of the statement
+		if err != nil && !errors.Is(err, gobatis.ErrorNotFound) {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}
+}
+
+// dbSystem db.system.name of the dialect, other_sql if the custom dialect not set it
+func dbSystem(info *gobatis.SpanInfo) string {
+	if info.System == `` {
+		return `other_sql`
+	}
+	return info.System
+}
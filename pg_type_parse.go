@@ -44,6 +44,9 @@ var (
 	// SplitPgArrayRangeType split string used by bufio.Scanner Split func
 	SplitPgArrayRangeType = SplitByString(`{"[(,)]}`)
 
+	// SplitPgVectorType split string used by bufio.Scanner Split func
+	SplitPgVectorType = SplitByString(`[,]`)
+
 	// SplitMoreCharsPrefix every prefix in the SplitMoreCharsPrefix will be split togother.
 	SplitMoreCharsPrefix = []string{`\"`}
 )
@@ -0,0 +1,108 @@
+package gobatis
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestExpandedSlicePlaceholders(t *testing.T) {
+	db, _ := openTest(t, `<mapper type="postgres">
+<insert id="insert">insert into users (id, name) select id, #{name} from orders where id in (#{ids}) and tag = #{tag}</insert>
+</mapper>`)
+
+	ndb := db.Mapper(`insert`).Args(Args{`name`: `a`, `ids`: []int{1, 2, 3}, `tag`: `x`})
+	if ndb.Error != nil {
+		t.Fatalf(`Args() error = %v`, ndb.Error)
+	}
+	statement, args, err := ndb.bindVars.Vars()
+	if err != nil {
+		t.Fatalf(`Vars() error = %v`, err)
+	}
+	if want := `insert into users (id, name) select id, $1 from orders where id in ($2, $3, $4) and tag = $5`; statement != want {
+		t.Errorf("statement\n got: %q\nwant: %q", statement, want)
+	}
+	if want := []interface{}{`a`, 1, 2, 3, `x`}; !reflect.DeepEqual(args, want) {
+		t.Errorf(`args = %#v, want %#v`, args, want)
+	}
+}
+
+const renderMapper = `<mapper namespace="users">
+<sql id="columns">${t}.id, ${t}.name</sql>
+<select id="byId">select * from users where id = #{id}</select>
+<select id="include">select <include refid="columns" alias="t" value="u"/> from users u</select>
+<select id="in">select * from users where id in (#{ids}) and name = #{name}</select>
+<select id="raw">select * from users order by ${order}</select>
+<select id="elif">select * from users where <if test="id > 10">id = #{id}</if><elif test="id > 5">id > #{id}</elif><else>id &lt; #{id}</else></select>
+<select id="choose">select * from users where <choose><when test="id != nil">id = #{id}</when><when test="name != nil">name = #{name}</when><otherwise>id = 100</otherwise></choose></select>
+</mapper>`
+
+func TestRender(t *testing.T) {
+	db, server := openTest(t, renderMapper)
+
+	tests := []struct {
+		name      string
+		id        string
+		variables Args
+		statement string
+		args      []interface{}
+	}{
+		{`param`, `byId`, Args{`id`: 1}, `select * from users where id = ?`, []interface{}{1}},
+		{`include alias`, `include`, Args{}, `select u.id, u.name from users u`, nil},
+		{`expand slice`, `in`, Args{`ids`: []int{1, 2, 3}, `name`: `a`}, `select * from users where id in (?, ?, ?) and name = ?`, []interface{}{1, 2, 3, `a`}},
+		{`raw text`, `raw`, Args{`order`: `id desc`}, `select * from users order by id desc`, nil},
+		{`if`, `elif`, Args{`id`: 11}, `select * from users where id = ?`, []interface{}{11}},
+		{`elif`, `elif`, Args{`id`: 6}, `select * from users where id > ?`, []interface{}{6}},
+		{`else`, `elif`, Args{`id`: 1}, `select * from users where id < ?`, []interface{}{1}},
+		{`when first`, `choose`, Args{`id`: 1, `name`: `a`}, `select * from users where id = ?`, []interface{}{1}},
+		{`when second`, `choose`, Args{`id`: nil, `name`: `a`}, `select * from users where name = ?`, []interface{}{`a`}},
+		{`otherwise`, `choose`, Args{`id`: nil, `name`: nil}, `select * from users where id = 100`, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			statement, args, err := db.Mapper(tt.id).Render(tt.variables)
+			if err != nil {
+				t.Fatalf(`Render() error = %v`, err)
+			}
+			if statement != tt.statement {
+				t.Errorf("Render() statement\n got: %q\nwant: %q", statement, tt.statement)
+			}
+			if (len(args) != 0 || len(tt.args) != 0) && !reflect.DeepEqual(args, tt.args) {
+				t.Errorf(`Render() args = %#v, want %#v`, args, tt.args)
+			}
+		})
+	}
+
+	// nothing is executed
+	if statements := server.log(); len(statements) != 0 {
+		t.Errorf(`Render() executed %v`, statements)
+	}
+}
+
+func TestRenderErrors(t *testing.T) {
+	db, _ := openTest(t, renderMapper)
+
+	if _, _, err := db.Mapper(`missing`).Render(Args{}); err == nil {
+		t.Error(`Render() of missing mapper, want error`)
+	}
+	if _, _, err := db.Render(Args{}); !errors.Is(err, ErrorMapperCallFirst) {
+		t.Errorf(`Render() without mapper error = %v, want %v`, err, ErrorMapperCallFirst)
+	}
+}
+
+func TestInterpolatedSQL(t *testing.T) {
+	db, server := openTest(t, `<mapper type="postgres">
+<select id="find">select * from users where name = #{name} and id in (#{ids})</select>
+</mapper>`)
+
+	statement, err := db.Mapper(`find`).InterpolatedSQL(Args{`name`: `o'neil`, `ids`: []int{1, 2}})
+	if err != nil {
+		t.Fatalf(`InterpolatedSQL() error = %v`, err)
+	}
+	if want := `select * from users where name = 'o''neil' and id in (1, 2)`; statement != want {
+		t.Errorf("InterpolatedSQL()\n got: %q\nwant: %q", statement, want)
+	}
+	if statements := server.log(); len(statements) != 0 {
+		t.Errorf(`InterpolatedSQL() executed %v`, statements)
+	}
+}
@@ -0,0 +1,306 @@
+package gobatis
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ReplicaPolicy how the selects are routed among the healthy replicas
+type ReplicaPolicy int
+
+const (
+	// RoundRobin route the selects to the replicas in turn
+	RoundRobin ReplicaPolicy = iota
+	// LeastConnections route the select to the replica with the fewest connections in use
+	LeastConnections
+)
+
+// defaultHealthCheckInterval interval of pinging the replicas
+const defaultHealthCheckInterval = 5 * time.Second
+
+type replica struct {
+	db *sql.DB
+	// owned opened from dsn and closed by Close, the *sql.DB of WithReplicaDBs is closed by the caller
+	owned bool
+	// prepared statements cache of the replica, nil if disabled
+	stmts *stmtCache
+	// down 1 if the last health check failed
+	down int32
+}
+
+func (r *replica) healthy() bool {
+	return atomic.LoadInt32(&r.down) == 0
+}
+
+// replicaSet the read replicas of the primary database
+type replicaSet struct {
+	dsns     []string
+	replicas []*replica
+	policy   ReplicaPolicy
+	interval time.Duration
+	next     uint32
+
+	// read-your-writes, the selects of key go to primary within window after its write
+	window time.Duration
+	key    func(ctx context.Context) string
+	mu     sync.Mutex
+	writes map[string]time.Time
+	swept  time.Time
+
+	stop      chan struct{}
+	closeOnce sync.Once
+}
+
+// replicaSetOf return the replicas of db, created if not set
+func replicaSetOf(db *DB) *replicaSet {
+	if db.replicas == nil {
+		db.replicas = &replicaSet{interval: defaultHealthCheckInterval}
+	}
+	return db.replicas
+}
+
+// WithReplicas open the read replicas of the primary with dsns by the same driver,
+// the selects outside transaction are routed to the healthy replicas.
+//
+// forexample:
+//
+//	gobatis.Open(`pgx`, primaryDsn, gobatis.WithReplicas(replicaDsn1, replicaDsn2))
+func WithReplicas(dsns ...string) func(*DB) {
+	return func(db *DB) {
+		set := replicaSetOf(db)
+		set.dsns = append(set.dsns, dsns...)
+	}
+}
+
+// WithReplicaDBs use the opened databases as read replicas, they're not closed by Close
+func WithReplicaDBs(dbs ...*sql.DB) func(*DB) {
+	return func(db *DB) {
+		set := replicaSetOf(db)
+		for _, replicaDB := range dbs {
+			set.replicas = append(set.replicas, &replica{db: replicaDB})
+		}
+	}
+}
+
+// WithReplicaPolicy set how the selects are routed among the replicas, RoundRobin by default
+func WithReplicaPolicy(policy ReplicaPolicy) func(*DB) {
+	return func(db *DB) {
+		replicaSetOf(db).policy = policy
+	}
+}
+
+// WithHealthCheck ping the replicas every interval, the failed ones are skipped until the ping succeeded,
+// the selects go to primary if all the replicas are down. 5s by default, interval <= 0 disables it.
+func WithHealthCheck(interval time.Duration) func(*DB) {
+	return func(db *DB) {
+		replicaSetOf(db).interval = interval
+	}
+}
+
+// WithReadYourWrites route the selects to primary within window after a write of the same key,
+// so the caller reads its own writes before the replicas caught up.
+// key identifies the caller from the context of statements, such as the user or session id,
+// the statements with empty key are not sticky.
+func WithReadYourWrites(window time.Duration, key func(ctx context.Context) string) func(*DB) {
+	return func(db *DB) {
+		set := replicaSetOf(db)
+		set.window, set.key = window, key
+	}
+}
+
+// UsePrimary route all the statements to the primary database, including the selects outside transaction
+func (b *DB) UsePrimary() *DB {
+	db := b.Clone()
+	db.usePrimary = true
+	return db
+}
+
+// open the replicas of dsns, with the prepared statements cache like primary's
+func (s *replicaSet) open(driverName string, stmts *stmtCache) error {
+	for _, dsn := range s.dsns {
+		db, err := sql.Open(driverName, dsn)
+		if err != nil {
+			_ = s.close()
+			return err
+		}
+		s.replicas = append(s.replicas, &replica{db: db, owned: true})
+	}
+	if stmts != nil {
+		for _, r := range s.replicas {
+			r.stmts = newStmtCache(stmts.size)
+		}
+	}
+
+	if len(s.replicas) > 0 && s.interval > 0 {
+		s.stop = make(chan struct{})
+		go s.healthCheck()
+	}
+	return nil
+}
+
+// healthCheck ping the replicas every interval until closed
+func (s *replicaSet) healthCheck() {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+		}
+
+		for _, r := range s.replicas {
+			ctx, cancel := context.WithTimeout(context.Background(), s.interval)
+			var down int32
+			if err := r.db.PingContext(ctx); err != nil {
+				down = 1
+			}
+			cancel()
+			atomic.StoreInt32(&r.down, down)
+		}
+	}
+}
+
+// pick return the replica of the next select, nil if all the replicas are down
+func (s *replicaSet) pick() *replica {
+	if s.policy == LeastConnections {
+		var picked *replica
+		var inUse int
+		for _, r := range s.replicas {
+			if !r.healthy() {
+				continue
+			}
+			if n := r.db.Stats().InUse; picked == nil || n < inUse {
+				picked, inUse = r, n
+			}
+		}
+		return picked
+	}
+
+	n := uint32(len(s.replicas))
+	start := atomic.AddUint32(&s.next, 1)
+	for i := uint32(0); i < n; i++ {
+		if r := s.replicas[(start+i)%n]; r.healthy() {
+			return r
+		}
+	}
+	return nil
+}
+
+// written record the write of ctx, only refresh the recorded one if existing is true
+func (s *replicaSet) written(ctx context.Context, existing bool) {
+	if s.window <= 0 || s.key == nil {
+		return
+	}
+	key := s.key(ctx)
+	if key == `` {
+		return
+	}
+
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.writes[key]; existing && !ok {
+		return
+	}
+	if s.writes == nil {
+		s.writes = make(map[string]time.Time)
+	}
+	s.writes[key] = now
+
+	// drop the expired ones at most once a window
+	if now.Sub(s.swept) >= s.window {
+		for k, t := range s.writes {
+			if now.Sub(t) >= s.window {
+				delete(s.writes, k)
+			}
+		}
+		s.swept = now
+	}
+}
+
+// sticky report whether ctx written within the window
+func (s *replicaSet) sticky(ctx context.Context) bool {
+	if s.window <= 0 || s.key == nil {
+		return false
+	}
+	key := s.key(ctx)
+	if key == `` {
+		return false
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, ok := s.writes[key]
+	return ok && time.Since(t) < s.window
+}
+
+// purge the prepared statements cache of the replicas
+func (s *replicaSet) purge() {
+	for _, r := range s.replicas {
+		if r.stmts != nil {
+			r.stmts.purge()
+		}
+	}
+}
+
+// close stop the health check, and close the replicas opened from dsns
+func (s *replicaSet) close() (err error) {
+	s.closeOnce.Do(func() {
+		if s.stop != nil {
+			close(s.stop)
+		}
+		s.purge()
+		for _, r := range s.replicas {
+			if !r.owned {
+				continue
+			}
+			if closeErr := r.db.Close(); err == nil {
+				err = closeErr
+			}
+		}
+	})
+	return err
+}
+
+// replica return the replica the statement routed to, nil for the primary
+func (b *DB) replica() *replica {
+	if b.replicas == nil || b.tx != nil {
+		return nil
+	}
+	if _, ok := b.mapper.(*Select); !ok {
+		return nil
+	}
+	if b.forcedPrimary() {
+		return nil
+	}
+	return b.replicas.pick()
+}
+
+// forcedPrimary report whether the select is forced to primary for the fresh result,
+// by UsePrimary, master="true" or read-your-writes. such selects are never cached or shared,
+// as the result of replicas may be stale.
+func (b *DB) forcedPrimary() bool {
+	if b.replicas == nil {
+		return false
+	}
+	if b.usePrimary {
+		return true
+	}
+	if value, _ := b.mapperAttr(MasterKey); strings.EqualFold(value, `true`) {
+		return true
+	}
+	return b.replicas.sticky(b.ctx)
+}
+
+// written record the write of the statement for read-your-writes
+func (b *DB) written() {
+	if b.replicas != nil {
+		b.replicas.written(b.ctx, false)
+	}
+}
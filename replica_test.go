@@ -0,0 +1,203 @@
+package gobatis
+
+import (
+	"context"
+	"database/sql/driver"
+	"testing"
+	"time"
+)
+
+const replicaMapper = `<mapper>
+<select id="find">select * from users where id = #{id}</select>
+<select id="master" master="true">select * from users where id = #{id}</select>
+<select id="cached" cache="true">select * from users where id = #{id}</select>
+<update id="update">update users set name = #{name}</update>
+<insert id="insert">insert into users (name) values (#{name}) returning id</insert>
+</mapper>`
+
+const replicaQuery = `select * from users where id = ?`
+
+// openReplicas open the fake database of the test with the replicas
+func openReplicas(t *testing.T, n int, opts ...func(*DB)) (*DB, *testServer, []*testServer) {
+	t.Helper()
+	var dsns []string
+	var replicas []*testServer
+	for i := 0; i < n; i++ {
+		dsn := t.Name() + `/replica` + string(rune('0'+i))
+		server := &testServer{}
+		server.setRows([]string{`id`}, []driver.Value{int64(i)})
+		testServers.Store(dsn, server)
+		dsns, replicas = append(dsns, dsn), append(replicas, server)
+	}
+	db, primary := openTest(t, replicaMapper, append([]func(*DB){WithReplicas(dsns...)}, opts...)...)
+	t.Cleanup(func() {
+		_ = db.Close()
+	})
+	return db, primary, replicas
+}
+
+func findUser(t *testing.T, db *DB, id string) {
+	t.Helper()
+	var users []scanUser
+	if err := db.Mapper(id).Args(Args{`id`: 1}).Find(&users).Error; err != nil {
+		t.Fatalf(`Find() error = %v`, err)
+	}
+}
+
+func TestReplicaRoundRobin(t *testing.T) {
+	db, primary, replicas := openReplicas(t, 2)
+
+	for i := 0; i < 4; i++ {
+		findUser(t, db, `find`)
+	}
+	if err := db.Mapper(`update`).Args(Args{`name`: `a`}).Execute().Error; err != nil {
+		t.Fatalf(`Execute() error = %v`, err)
+	}
+
+	if n0, n1 := replicas[0].count(replicaQuery), replicas[1].count(replicaQuery); n0 != 2 || n1 != 2 {
+		t.Errorf(`replicas queried %d and %d times, want 2 and 2`, n0, n1)
+	}
+	if n := primary.count(replicaQuery); n != 0 {
+		t.Errorf(`primary queried %d times`, n)
+	}
+	if n := primary.count(`update users set name = ?`); n != 1 {
+		t.Errorf(`primary updated %d times, want 1`, n)
+	}
+}
+
+func TestReplicaPrimaryRoutes(t *testing.T) {
+	db, primary, replicas := openReplicas(t, 1)
+
+	findUser(t, db, `master`)
+	findUser(t, db.UsePrimary(), `find`)
+	err := db.Transaction(func(tx *DB) error {
+		findUser(t, tx, `find`)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf(`Transaction() error = %v`, err)
+	}
+
+	if n := primary.count(replicaQuery); n != 3 {
+		t.Errorf(`primary queried %d times, want 3`, n)
+	}
+	if n := replicas[0].count(replicaQuery); n != 0 {
+		t.Errorf(`replica queried %d times`, n)
+	}
+}
+
+type userKey struct{}
+
+func TestReadYourWrites(t *testing.T) {
+	db, primary, replicas := openReplicas(t, 1, WithReadYourWrites(100*time.Millisecond, func(ctx context.Context) string {
+		user, _ := ctx.Value(userKey{}).(string)
+		return user
+	}))
+	alice := db.WithContext(context.WithValue(context.Background(), userKey{}, `alice`))
+	bob := db.WithContext(context.WithValue(context.Background(), userKey{}, `bob`))
+
+	if err := alice.Mapper(`update`).Args(Args{`name`: `a`}).Execute().Error; err != nil {
+		t.Fatalf(`Execute() error = %v`, err)
+	}
+	// alice reads her writes from primary within the window, bob reads from replica
+	findUser(t, alice, `find`)
+	findUser(t, bob, `find`)
+	if p, r := primary.count(replicaQuery), replicas[0].count(replicaQuery); p != 1 || r != 1 {
+		t.Errorf(`primary queried %d times, replica %d times, want 1 and 1`, p, r)
+	}
+
+	time.Sleep(150 * time.Millisecond)
+	findUser(t, alice, `find`)
+	if r := replicas[0].count(replicaQuery); r != 2 {
+		t.Errorf(`replica queried %d times after the window, want 2`, r)
+	}
+}
+
+func TestReadYourWritesReturning(t *testing.T) {
+	db, primary, replicas := openReplicas(t, 1, WithReadYourWrites(time.Minute, func(ctx context.Context) string {
+		user, _ := ctx.Value(userKey{}).(string)
+		return user
+	}))
+	alice := db.WithContext(context.WithValue(context.Background(), userKey{}, `alice`))
+
+	// the write by Find with returning is recorded like Execute
+	var users []scanUser
+	if err := alice.Mapper(`insert`).Args(Args{`name`: `a`}).Find(&users).Error; err != nil {
+		t.Fatalf(`Find() error = %v`, err)
+	}
+	findUser(t, alice, `find`)
+	if p, r := primary.count(replicaQuery), replicas[0].count(replicaQuery); p != 1 || r != 0 {
+		t.Errorf(`primary queried %d times, replica %d times, want 1 and 0`, p, r)
+	}
+}
+
+func TestReplicaHealthCheck(t *testing.T) {
+	db, primary, replicas := openReplicas(t, 2, WithHealthCheck(10*time.Millisecond))
+	replicas[0].setDown(true)
+	time.Sleep(50 * time.Millisecond)
+
+	for i := 0; i < 4; i++ {
+		findUser(t, db, `find`)
+	}
+	if n0, n1 := replicas[0].count(replicaQuery), replicas[1].count(replicaQuery); n0 != 0 || n1 != 4 {
+		t.Errorf(`replicas queried %d and %d times, want 0 and 4`, n0, n1)
+	}
+
+	// all the replicas are down
+	replicas[1].setDown(true)
+	time.Sleep(50 * time.Millisecond)
+	findUser(t, db, `find`)
+	if n := primary.count(replicaQuery); n != 1 {
+		t.Errorf(`primary queried %d times, want 1`, n)
+	}
+
+	replicas[0].setDown(false)
+	time.Sleep(50 * time.Millisecond)
+	findUser(t, db, `find`)
+	if n := replicas[0].count(replicaQuery); n != 1 {
+		t.Errorf(`recovered replica queried %d times, want 1`, n)
+	}
+}
+
+func TestReplicaPrimaryNotCached(t *testing.T) {
+	db, primary, replicas := openReplicas(t, 1, WithCache(NewMemoryCache(10)), WithReadYourWrites(time.Minute, func(ctx context.Context) string {
+		user, _ := ctx.Value(userKey{}).(string)
+		return user
+	}))
+
+	// the result of replica is cached, the selects forced to primary read it fresh
+	findUser(t, db, `cached`)
+	findUser(t, db, `cached`)
+	findUser(t, db.UsePrimary(), `cached`)
+	if p, r := primary.count(replicaQuery), replicas[0].count(replicaQuery); p != 1 || r != 1 {
+		t.Errorf(`primary queried %d times, replica %d times, want 1 and 1`, p, r)
+	}
+
+	alice := db.WithContext(context.WithValue(context.Background(), userKey{}, `alice`))
+	if err := alice.RawExec(`update orders set state = 1`).Error; err != nil {
+		t.Fatalf(`RawExec() error = %v`, err)
+	}
+	findUser(t, alice, `cached`)
+	findUser(t, alice, `cached`)
+	if p := primary.count(replicaQuery); p != 3 {
+		t.Errorf(`primary queried %d times, want 3`, p)
+	}
+}
+
+func TestReplicaPrimaryNotShared(t *testing.T) {
+	db, primary, replicas := openReplicas(t, 1, WithSharedQueries())
+	replicas[0].setDelay(200 * time.Millisecond)
+
+	// the select forced to primary doesn't wait for the one of replica
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		findUser(t, db, `find`)
+	}()
+	time.Sleep(20 * time.Millisecond)
+	findUser(t, db.UsePrimary(), `find`)
+	<-done
+	if p, r := primary.count(replicaQuery), replicas[0].count(replicaQuery); p != 1 || r != 1 {
+		t.Errorf(`primary queried %d times, replica %d times, want 1 and 1`, p, r)
+	}
+}
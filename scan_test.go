@@ -0,0 +1,185 @@
+package gobatis
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"testing"
+)
+
+const scanMapper = `<mapper>
+<select id="find">select * from users</select>
+<select id="strict" strict="true">select * from users</select>
+</mapper>`
+
+type scanUser struct {
+	Id   int64  `sql:"id"`
+	Name string `sql:"name"`
+	Note string `sql:"note,omitempty"`
+}
+
+func TestScanStruct(t *testing.T) {
+	db, server := openTest(t, scanMapper)
+	server.setRows([]string{`u.id`, `name`, `extra`},
+		[]driver.Value{int64(1), `a`, `x`},
+		[]driver.Value{int64(2), `b`, `y`},
+	)
+
+	var users []scanUser
+	if err := db.Mapper(`find`).Args(Args{}).Find(&users).Error; err != nil {
+		t.Fatalf(`Find() error = %v`, err)
+	}
+	want := []scanUser{{Id: 1, Name: `a`}, {Id: 2, Name: `b`}}
+	if len(users) != len(want) || users[0] != want[0] || users[1] != want[1] {
+		t.Errorf(`Find() = %+v, want %+v`, users, want)
+	}
+}
+
+func TestStrictScan(t *testing.T) {
+	tests := []struct {
+		name    string
+		id      string
+		opts    []func(*DB)
+		columns []string
+		err     string
+	}{
+		{`unmapped column`, `strict`, nil, []string{`id`, `name`, `extra`}, `unmapped columns: [extra], unfilled fields: []`},
+		{`unfilled field`, `strict`, nil, []string{`id`}, `unmapped columns: [], unfilled fields: [Name]`},
+		{`option`, `find`, []func(*DB){WithStrictScan()}, []string{`id`, `extra`}, `unmapped columns: [extra], unfilled fields: [Name]`},
+		{`omitempty field`, `strict`, nil, []string{`id`, `name`}, ``},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, server := openTest(t, scanMapper, tt.opts...)
+			row := make([]driver.Value, len(tt.columns))
+			for i := range row {
+				row[i] = int64(1)
+			}
+			server.setRows(tt.columns, row)
+
+			var users []scanUser
+			err := db.Mapper(tt.id).Args(Args{}).Find(&users).Error
+			if tt.err == `` {
+				if err != nil {
+					t.Fatalf(`Find() error = %v`, err)
+				}
+				return
+			}
+			if !errors.Is(err, ErrorStrictScan) || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf(`Find() error = %v, want %v with %q`, err, ErrorStrictScan, tt.err)
+			}
+		})
+	}
+}
+
+func TestScanNullError(t *testing.T) {
+	db, server := openTest(t, scanMapper)
+	server.setRows([]string{`id`, `name`}, []driver.Value{int64(1), nil})
+
+	var users []scanUser
+	err := db.Mapper(`find`).Args(Args{}).Find(&users).Error
+	if err == nil || !strings.Contains(err.Error(), `column "name" is NULL but field scanUser.Name (string) is not nullable`) {
+		t.Errorf(`Find() error = %v`, err)
+	}
+
+	// NULL into the nullable fields is fine
+	type nullableUser struct {
+		Id   int64          `sql:"id"`
+		Name sql.NullString `sql:"name"`
+	}
+	var nullables []nullableUser
+	if err := db.Mapper(`find`).Args(Args{}).Find(&nullables).Error; err != nil {
+		t.Fatalf(`Find() error = %v`, err)
+	}
+	if len(nullables) != 1 || nullables[0].Name.Valid {
+		t.Errorf(`Find() = %+v`, nullables)
+	}
+}
+
+func TestScanDirect(t *testing.T) {
+	db, server := openTest(t, scanMapper)
+	rows := [][]driver.Value{
+		{int64(1), `a`, `x`},
+		{int64(2), `b`, `y`},
+		{int64(3), `c`, `z`},
+	}
+	server.setRows([]string{`id`, `name`, `extra`}, rows...)
+
+	// the holders are reused across rows, each element gets the values of it's own row
+	var pointers []*scanUser
+	if err := db.Mapper(`find`).Args(Args{}).Find(&pointers).Error; err != nil {
+		t.Fatalf(`Find() error = %v`, err)
+	}
+	if len(pointers) != 3 || pointers[0] == pointers[1] {
+		t.Fatalf(`Find() = %v`, pointers)
+	}
+	for i, user := range pointers {
+		if user.Id != rows[i][0] || user.Name != rows[i][1] {
+			t.Errorf(`Find()[%d] = %+v, want %v`, i, user, rows[i])
+		}
+	}
+
+	var maps []map[string]any
+	if err := db.Mapper(`find`).Args(Args{}).Find(&maps).Error; err != nil {
+		t.Fatalf(`Find() error = %v`, err)
+	}
+	if len(maps) != 3 {
+		t.Fatalf(`Find() = %v`, maps)
+	}
+	for i, m := range maps {
+		if m[`id`] != rows[i][0] || m[`name`] != rows[i][1] || m[`extra`] != rows[i][2] {
+			t.Errorf(`Find()[%d] = %v, want %v`, i, m, rows[i])
+		}
+	}
+
+	// the elements are appended to the slice
+	users := []scanUser{{Id: 100}}
+	if err := db.Mapper(`find`).Args(Args{}).Find(&users).Error; err != nil {
+		t.Fatalf(`Find() error = %v`, err)
+	}
+	if len(users) != 4 || users[0].Id != 100 || users[3].Name != `c` {
+		t.Errorf(`Find() = %+v`, users)
+	}
+
+	var user scanUser
+	if err := db.Mapper(`find`).Args(Args{}).Find(&user).Error; err != nil || user.Id != 1 || user.Name != `a` {
+		t.Errorf(`Find() = %+v, %v`, user, err)
+	}
+}
+
+func TestScanDirectFailedRow(t *testing.T) {
+	db, server := openTest(t, scanMapper)
+	server.setRows([]string{`id`, `name`},
+		[]driver.Value{int64(1), `a`},
+		[]driver.Value{int64(2), nil},
+	)
+
+	// the element of the failed row is removed
+	var users []scanUser
+	if err := db.Mapper(`find`).Args(Args{}).Find(&users).Error; err == nil {
+		t.Fatal(`Find() error = nil, want NULL error`)
+	}
+	if len(users) != 1 || users[0].Id != 1 {
+		t.Errorf(`Find() = %+v`, users)
+	}
+}
+
+func TestFindReturning(t *testing.T) {
+	db, server := openTest(t, `<mapper>
+<insert id="insert">insert into users (name) values (#{name}) returning id, name</insert>
+</mapper>`)
+	server.setRows([]string{`id`, `name`}, []driver.Value{int64(7), `a`})
+
+	// the writes with returning are run by Find, and the returned rows are scanned
+	var user scanUser
+	if err := db.Mapper(`insert`).Args(Args{`name`: `a`}).Find(&user).Error; err != nil {
+		t.Fatalf(`Find() error = %v`, err)
+	}
+	if user.Id != 7 || user.Name != `a` {
+		t.Errorf(`Find() = %+v`, user)
+	}
+	if n := server.count(`insert into users (name) values (?) returning id, name`); n != 1 {
+		t.Errorf(`executed %d times, want 1`, n)
+	}
+}
@@ -0,0 +1,68 @@
+package gobatis
+
+import (
+	"reflect"
+)
+
+// ctxVariable the context of the statement in expressions, forexample: <if test="hasRole(ctx, 'admin')">
+const ctxVariable = `ctx`
+
+// dialectVariable the dialect of the statement in expressions, forexample: ${dialect.Quote(column)}
+const dialectVariable = `dialect`
+
+// contextArgsVariable the variables derived from context by WithContextArgs, forexample: #{_ctx.tenant_id}
+const contextArgsVariable = `_ctx`
+
+// newScope copy the variables of input into the root scope of rendering,
+// the struct input is flattened by field names and column names,
+// so the caller's input is never modified and can be shared across goroutines.
+func newScope(input any) (map[string]any, error) {
+	value := reflect.ValueOf(input)
+	for value.Kind() == reflect.Ptr {
+		value = value.Elem()
+	}
+
+	switch value.Kind() {
+	case reflect.Struct:
+		return structVariables(value), nil
+	case reflect.Map:
+		if value.Type().Key().Kind() != reflect.String {
+			return nil, ErrorInputMustBeMap
+		}
+		if value.IsNil() {
+			return map[string]any{}, nil
+		}
+		scope := make(map[string]any, value.Len()+4)
+		if variables, ok := value.Interface().(map[string]any); ok {
+			for k, v := range variables {
+				scope[k] = v
+			}
+			return scope, nil
+		}
+		iter := value.MapRange()
+		for iter.Next() {
+			scope[iter.Key().String()] = iter.Value().Interface()
+		}
+		return scope, nil
+	default:
+		return nil, ErrorInputMustBeMap
+	}
+}
+
+// childScope copy parent into a new scope, the variables bound in child are invisible to parent
+func childScope(parent map[string]any, extra int) map[string]any {
+	scope := make(map[string]any, len(parent)+extra)
+	for k, v := range parent {
+		scope[k] = v
+	}
+	return scope
+}
+
+// mergeScope add the variables of env not defined in scope
+func mergeScope(scope map[string]any, env map[string]any) {
+	for k, v := range env {
+		if _, ok := scope[k]; !ok {
+			scope[k] = v
+		}
+	}
+}
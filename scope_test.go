@@ -0,0 +1,94 @@
+package gobatis
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+const scopeMapper = `<mapper type="postgres">
+<insert id="insert">insert into users (id, name) values <foreach collection="users" item="item" index="i" separator=",">(#{i}, #{item.Name})</foreach></insert>
+<select id="nested">select * from users where <foreach collection="groups" item="item" separator="or">(<foreach collection="item" item="item" separator="and"> id = #{item} </foreach>)</foreach> and name = #{item}</select>
+</mapper>`
+
+type scopeUser struct {
+	Name string
+}
+
+type scopeArgs struct {
+	Users []scopeUser
+}
+
+func TestForeachScope(t *testing.T) {
+	db, _ := openTest(t, scopeMapper)
+
+	variables := Args{`users`: []scopeUser{{`a`}, {`b`}}, `item`: `kept`}
+	statement, args, err := db.Mapper(`insert`).Render(variables)
+	if err != nil {
+		t.Fatalf(`Render() error = %v`, err)
+	}
+	if want := `insert into users (id, name) values ($1, $2),($3, $4)`; statement != want {
+		t.Errorf("Render()\n got: %q\nwant: %q", statement, want)
+	}
+	if want := []interface{}{0, `a`, 1, `b`}; !reflect.DeepEqual(args, want) {
+		t.Errorf(`Render() args = %#v, want %#v`, args, want)
+	}
+	// item & index are bound in the child scope only
+	if _, ok := variables[`i`]; ok || variables[`item`] != `kept` || len(variables) != 2 {
+		t.Errorf(`Render() modified the args: %v`, variables)
+	}
+
+	// the shadowed item is restored after the loops
+	statement, args, err = db.Mapper(`nested`).Render(Args{`groups`: [][]int{{1, 2}, {3}}, `item`: `a`})
+	if err != nil {
+		t.Fatalf(`Render() error = %v`, err)
+	}
+	if want := `select * from users where ( id = $1 and id = $2 )or( id = $3 ) and name = $4`; statement != want {
+		t.Errorf("Render()\n got: %q\nwant: %q", statement, want)
+	}
+	if want := []interface{}{1, 2, 3, `a`}; !reflect.DeepEqual(args, want) {
+		t.Errorf(`Render() args = %#v, want %#v`, args, want)
+	}
+}
+
+func TestForeachStructArgs(t *testing.T) {
+	db, _ := openTest(t, scopeMapper)
+
+	_, args, err := db.Mapper(`insert`).Render(&scopeArgs{Users: []scopeUser{{`a`}}})
+	if err != nil {
+		t.Fatalf(`Render() error = %v`, err)
+	}
+	if want := []interface{}{0, `a`}; !reflect.DeepEqual(args, want) {
+		t.Errorf(`Render() args = %#v, want %#v`, args, want)
+	}
+
+	if _, _, err := db.Mapper(`insert`).Render(map[int]any{1: 1}); !errors.Is(err, ErrorInputMustBeMap) {
+		t.Errorf(`Render() error = %v, want %v`, err, ErrorInputMustBeMap)
+	}
+}
+
+func TestForeachSharedArgs(t *testing.T) {
+	db, _ := openTest(t, scopeMapper)
+	variables := Args{`users`: []scopeUser{{`a`}, {`b`}, {`c`}}}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				if _, args, err := db.Mapper(`insert`).Render(variables); err != nil || len(args) != 6 {
+					errs <- err
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Errorf(`Render() with shared args error = %v`, err)
+	}
+}
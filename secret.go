@@ -0,0 +1,174 @@
+package gobatis
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"reflect"
+	"strings"
+)
+
+const (
+	// SensitiveKey statement attribute, list of sensitive args split by `,`
+	// forexample: <insert id="addUser" sensitive="password,token">
+	SensitiveKey = `sensitive`
+
+	// SecretMask the mask of sensitive values in logs and interpolated sql
+	SecretMask = `******`
+
+	// gobatisTag struct tag of gobatis options, forexample: `json:"password" gobatis:"sensitive"`
+	gobatisTag = `gobatis`
+)
+
+// SecretValue wraps a sensitive value, it's masked in Logger args, Error args and interpolated sql,
+// but sent to the driver unchanged.
+type SecretValue struct {
+	value any
+}
+
+// Secret mark the value as sensitive
+//
+// forexample:
+//
+//	db.Mapper(`addUser`).Args(&gobatis.Args{`name`: name, `password`: gobatis.Secret(password)}).Execute()
+func Secret(v any) SecretValue {
+	if s, ok := v.(SecretValue); ok {
+		return s
+	}
+	return SecretValue{value: v}
+}
+
+// Unwrap return the raw value
+func (s SecretValue) Unwrap() any {
+	return s.value
+}
+
+func (s SecretValue) String() string {
+	return SecretMask
+}
+
+func (s SecretValue) GoString() string {
+	return SecretMask
+}
+
+func (s SecretValue) MarshalJSON() ([]byte, error) {
+	return json.Marshal(SecretMask)
+}
+
+// Value sql/database Value interface, used when passed to the driver directly
+func (s SecretValue) Value() (driver.Value, error) {
+	return driver.DefaultParameterConverter.ConvertValue(s.value)
+}
+
+// unwrapSecret return the raw value and whether it's a secret
+func unwrapSecret(v any) (any, bool) {
+	if s, ok := v.(SecretValue); ok {
+		return s.value, true
+	}
+	return v, false
+}
+
+// unwrapSecrets return args with the secret ones unwrapped and which args are secret,
+// args is returned as it is if no secret found.
+func unwrapSecrets(args []any) ([]any, []bool) {
+	var masks []bool
+	var unwrapped []any
+	for i, arg := range args {
+		if v, ok := unwrapSecret(arg); ok {
+			if masks == nil {
+				masks = make([]bool, len(args))
+				unwrapped = append(make([]any, 0, len(args)), args...)
+			}
+			unwrapped[i], masks[i] = v, true
+		}
+	}
+	if masks == nil {
+		return args, nil
+	}
+	return unwrapped, masks
+}
+
+// maskArgs return a copy of args with the secret ones masked
+func maskArgs(args []any, masks []bool) []any {
+	if masks == nil {
+		return args
+	}
+	masked := make([]any, len(args))
+	for i, arg := range args {
+		if i < len(masks) && masks[i] {
+			arg = Secret(arg)
+		}
+		masked[i] = arg
+	}
+	return masked
+}
+
+// fieldSensitive report whether the struct field is tagged with gobatis:"sensitive"
+func fieldSensitive(f reflect.StructField) bool {
+	for _, option := range strings.Split(f.Tag.Get(gobatisTag), `,`) {
+		if strings.TrimSpace(option) == `sensitive` {
+			return true
+		}
+	}
+	return false
+}
+
+// collectSensitive collect names of the sensitive fields in typ and the nested types
+func collectSensitive(typ reflect.Type, names map[string]bool, seen map[reflect.Type]bool) {
+	for {
+		switch typ.Kind() {
+		case reflect.Ptr, reflect.Slice, reflect.Array, reflect.Map:
+			typ = typ.Elem()
+			continue
+		}
+		break
+	}
+	if typ.Kind() != reflect.Struct || seen[typ] {
+		return
+	}
+	seen[typ] = true
+
+	for i := 0; i < typ.NumField(); i++ {
+		f := typ.Field(i)
+		if f.PkgPath != `` {
+			continue
+		}
+		if fieldSensitive(f) {
+			names[f.Name] = true
+			names[fieldColumn(f)] = true
+		}
+		collectSensitive(f.Type, names, seen)
+	}
+}
+
+// sensitiveNames parse the sensitive attribute of statement
+func sensitiveNames(attrMap map[string]string, names map[string]bool) map[string]bool {
+	value, ok := attrMap[SensitiveKey]
+	if !ok || strings.TrimSpace(value) == `` {
+		return names
+	}
+	merged := make(map[string]bool, len(names)+4)
+	for name := range names {
+		merged[name] = true
+	}
+	for _, name := range strings.Split(value, `,`) {
+		if name = strings.TrimSpace(name); name != `` {
+			merged[name] = true
+		}
+	}
+	return merged
+}
+
+// exprSensitive report whether any identifier of the expression is sensitive
+func exprSensitive(exprString string, names map[string]bool) bool {
+	if len(names) == 0 {
+		return false
+	}
+	for _, ident := range strings.FieldsFunc(exprString, func(r rune) bool {
+		return !(r == '_' || r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z')
+	}) {
+		if names[ident] {
+			return true
+		}
+	}
+	return false
+}
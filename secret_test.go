@@ -0,0 +1,100 @@
+package gobatis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// testLogger record the logs
+type testLogger struct {
+	mu   sync.Mutex
+	logs []string
+}
+
+func (l *testLogger) Log(ctx context.Context, level int, duration int64, sql string, args ...any) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.logs = append(l.logs, fmt.Sprintf(`%d %s %v`, level, sql, args))
+}
+
+func (l *testLogger) String() string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return strings.Join(l.logs, "\n")
+}
+
+const secretMapper = `<mapper type="postgres">
+<insert id="attr" sensitive="password">insert into users (name, password) values (#{name}, #{password})</insert>
+<insert id="tag">insert into users (name, password) values (#{Name}, #{Password})</insert>
+<insert id="plain">insert into users (name, password) values (#{name}, #{password})</insert>
+</mapper>`
+
+type secretUser struct {
+	Name     string
+	Password string `gobatis:"sensitive"`
+}
+
+func TestSecretMasking(t *testing.T) {
+	tests := []struct {
+		name      string
+		id        string
+		variables interface{}
+	}{
+		{`attribute`, `attr`, Args{`name`: `alice`, `password`: `hunter2`}},
+		{`struct tag`, `tag`, &secretUser{Name: `alice`, Password: `hunter2`}},
+		{`secret value`, `plain`, Args{`name`: `alice`, `password`: Secret(`hunter2`)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logger := &testLogger{}
+			db, server := openTest(t, secretMapper, WithLogger(logger))
+
+			statement, err := db.Mapper(tt.id).InterpolatedSQL(tt.variables)
+			if err != nil {
+				t.Fatalf(`InterpolatedSQL() error = %v`, err)
+			}
+			if want := `insert into users (name, password) values ('alice', '******')`; statement != want {
+				t.Errorf("InterpolatedSQL()\n got: %q\nwant: %q", statement, want)
+			}
+
+			if err := db.Mapper(tt.id).Args(tt.variables).Execute().Error; err != nil {
+				t.Fatalf(`Execute() error = %v`, err)
+			}
+			if logs := logger.String(); strings.Contains(logs, `hunter2`) || !strings.Contains(logs, `alice`) {
+				t.Errorf(`Execute() logged %q`, logs)
+			}
+
+			server.setErr(errors.New(`boom`))
+			err = db.Mapper(tt.id).Args(tt.variables).Execute().Error
+			var e *Error
+			if !errors.As(err, &e) || len(e.Args) != 2 {
+				t.Fatalf(`Execute() error = %#v`, err)
+			}
+			if got := fmt.Sprint(e.Args...); strings.Contains(got, `hunter2`) || !strings.Contains(got, `alice`) {
+				t.Errorf(`Error.Args = %q`, got)
+			}
+			if logs := logger.String(); strings.Contains(logs, `hunter2`) {
+				t.Errorf(`Execute() logged %q`, logs)
+			}
+		})
+	}
+}
+
+func TestSecretValue(t *testing.T) {
+	s := Secret(`hunter2`)
+	if Secret(s) != s || s.Unwrap() != `hunter2` {
+		t.Errorf(`Secret() = %#v`, s)
+	}
+	for _, got := range []string{fmt.Sprint(s), fmt.Sprintf(`%#v`, s), fmt.Sprintf(`%+v`, s)} {
+		if got != SecretMask {
+			t.Errorf(`formatted secret = %q, want %q`, got, SecretMask)
+		}
+	}
+	if v, err := s.Value(); err != nil || v != `hunter2` {
+		t.Errorf(`Value() = %v, %v`, v, err)
+	}
+}
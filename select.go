@@ -22,8 +22,12 @@ func NewSelect() *Select {
 	}
 }
 
-func (m *Select) Evaluate(ctx context.Context, input *HandlerPayload) (string, error) {
-	return intervalEvaluate(ctx, m.Children, input)
+func (m *Select) Evaluate(ctx context.Context, input *HandlerPayload) (*Fragments, error) {
+	var out Fragments
+	if err := intervalEvaluate(ctx, m.Children, input, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
 }
 
 func (m *Select) Bind(ctx context.Context, input *HandlerPayload) *BindVar {
@@ -49,7 +53,11 @@ func (m *Select) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
 				m.Children = append(m.Children, ele)
 			}
 		case xml.CharData:
-			m.Children = append(m.Children, el.Copy())
+			if text, err := NewText(el.Copy()); err != nil {
+				return err
+			} else {
+				m.Children = append(m.Children, text)
+			}
 		case xml.EndElement:
 			return nil
 		case xml.Comment, xml.ProcInst, xml.Directive:
@@ -0,0 +1,48 @@
+//go:build go1.21
+
+package gobatis
+
+import (
+	"context"
+	"log/slog"
+)
+
+// SlogLogger StructuredLogger writes to slog.Logger
+type SlogLogger struct {
+	logger *slog.Logger
+}
+
+// NewSlogLogger create StructuredLogger writes to logger, slog.Default() if nil
+func NewSlogLogger(logger *slog.Logger) *SlogLogger {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &SlogLogger{logger: logger}
+}
+
+func (l *SlogLogger) LogEvent(ctx context.Context, event *LogEvent) {
+	level := slog.LevelDebug
+	switch event.Level {
+	case LogLevelInfo:
+		level = slog.LevelInfo
+	case LogLevelError:
+		level = slog.LevelError
+	}
+	if !l.logger.Enabled(ctx, level) {
+		return
+	}
+
+	attrs := []slog.Attr{
+		slog.String(`mapper`, event.MapperID),
+		slog.String(`type`, event.Type),
+		slog.Bool(`tx`, event.InTransaction),
+		slog.Int64(`rows`, event.Rows),
+		slog.Duration(`duration`, event.Duration),
+		slog.String(`sql`, event.SQL),
+		slog.Any(`args`, event.Args),
+	}
+	if event.Err != nil {
+		attrs = append(attrs, slog.Any(`error`, event.Err))
+	}
+	l.logger.LogAttrs(ctx, level, `gobatis`, attrs...)
+}
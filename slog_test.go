@@ -0,0 +1,59 @@
+//go:build go1.21
+
+package gobatis
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"testing"
+)
+
+func TestSlogLogger(t *testing.T) {
+	var out bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&out, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	db, server := openTest(t, logMapper, WithStructuredLogger(NewSlogLogger(logger)))
+
+	server.setErr(errors.New(`boom`))
+	db.Mapper(`update`).Args(Args{`name`: `a`}).Execute()
+
+	var record map[string]any
+	if err := json.Unmarshal(out.Bytes(), &record); err != nil {
+		t.Fatalf("unmarshal %s: %v", out.String(), err)
+	}
+	want := map[string]any{
+		`level`:  `ERROR`,
+		`msg`:    `gobatis`,
+		`mapper`: `update`,
+		`type`:   `update`,
+		`tx`:     false,
+		`rows`:   float64(0),
+		`sql`:    `update users set name = ?`,
+		`args`:   []any{`a`},
+		`error`:  `gobatis: mapper update: exec: boom`,
+	}
+	for key, value := range want {
+		if got, ok := record[key]; !ok || !jsonEqual(got, value) {
+			t.Errorf(`attr %s = %v, want %v`, key, got, value)
+		}
+	}
+	if _, ok := record[`duration`]; !ok {
+		t.Error(`attr duration not found`)
+	}
+
+	// the disabled level is skipped
+	out.Reset()
+	quiet := slog.New(slog.NewJSONHandler(&out, &slog.HandlerOptions{Level: slog.LevelInfo}))
+	db, _ = openTest(t, logMapper, WithStructuredLogger(NewSlogLogger(quiet)))
+	db.Mapper(`update`).Args(Args{`name`: `a`}).Execute()
+	if out.Len() != 0 {
+		t.Errorf(`debug log sent to info logger: %s`, out.String())
+	}
+}
+
+func jsonEqual(a, b any) bool {
+	x, _ := json.Marshal(a)
+	y, _ := json.Marshal(b)
+	return bytes.Equal(x, y)
+}
@@ -0,0 +1,58 @@
+package gobatis
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// SlowQuery the statement exceeded the slow query threshold
+type SlowQuery struct {
+	Ctx      context.Context
+	MapperID string
+	// SQL and Args the prepared statement, the sensitive args are masked
+	SQL      string
+	Args     []any
+	Duration time.Duration
+	// Threshold the slow attribute of the statement or the threshold of WithSlowQueryThreshold
+	Threshold time.Duration
+}
+
+// SlowQueryHandler handle the slow statements, it's called synchronously after the statement finished
+type SlowQueryHandler func(query *SlowQuery)
+
+// slowQueryThreshold slow attribute of the statement (inherited from mapper), or the global threshold
+func (b *DB) slowQueryThreshold() (time.Duration, error) {
+	value, ok := b.mapperAttr(SlowKey)
+	if !ok || value == `` {
+		return b.slowThreshold, nil
+	}
+	threshold, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf(`gobatis: invalid slow="%s": %w`, value, err)
+	}
+	return threshold, nil
+}
+
+// detectSlow invoke the slow query handler if the statement started at startTime exceeded the threshold
+// the invalid slow attribute is logged as error, the statement is not affected.
+func (db *DB) detectSlow() {
+	if db.slowHandler == nil {
+		return
+	}
+	threshold, err := db.slowQueryThreshold()
+	if err != nil {
+		db.log(LogLevelError, db.wrapError(PhaseBind, err), ``)
+		return
+	}
+	duration := time.Since(db.startTime)
+	if threshold <= 0 || duration < threshold {
+		return
+	}
+
+	query := &SlowQuery{Ctx: db.ctx, MapperID: db.mapperId, Duration: duration, Threshold: threshold}
+	if db.bindVars != nil {
+		query.SQL, query.Args = db.bindVars.stateSql, maskArgs(db.bindVars.args, db.bindVars.masks)
+	}
+	db.slowHandler(query)
+}
@@ -0,0 +1,93 @@
+package gobatis
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const slowMapper = `<mapper slow="20ms">
+<select id="find" sensitive="password">select * from users where password = #{password}</select>
+<update id="update" slow="0s">update users set name = #{name}</update>
+<update id="global" slow="">update users set tag = #{tag}</update>
+</mapper>`
+
+func TestSlowQuery(t *testing.T) {
+	var mu sync.Mutex
+	var queries []*SlowQuery
+	db, server := openTest(t, slowMapper, WithSlowQueryThreshold(time.Hour, func(q *SlowQuery) {
+		mu.Lock()
+		defer mu.Unlock()
+		queries = append(queries, q)
+	}))
+	server.setDelay(40 * time.Millisecond)
+
+	var users []scanUser
+	if err := db.Mapper(`find`).Args(Args{`password`: `hunter2`}).Find(&users).Error; err != nil {
+		t.Fatalf(`Find() error = %v`, err)
+	}
+	// slow="0s" disables the detection, the global threshold is not exceeded
+	db.Mapper(`update`).Args(Args{`name`: `a`}).Execute()
+	db.Mapper(`global`).Args(Args{`tag`: `a`}).Execute()
+
+	if len(queries) != 1 {
+		t.Fatalf(`%d slow queries, want 1`, len(queries))
+	}
+	q := queries[0]
+	if q.MapperID != `find` || q.SQL != `select * from users where password = ?` || q.Threshold != 20*time.Millisecond || q.Duration < 40*time.Millisecond {
+		t.Errorf(`SlowQuery = %+v`, q)
+	}
+	if !reflect.DeepEqual(q.Args, []any{Secret(`hunter2`)}) || strings.Contains(q.Args[0].(SecretValue).String(), `hunter2`) {
+		t.Errorf(`SlowQuery.Args = %v, want masked`, q.Args)
+	}
+
+	// below the threshold
+	server.setDelay(0)
+	queries = nil
+	db.Mapper(`find`).Args(Args{`password`: `hunter2`}).Find(&users)
+	if len(queries) != 0 {
+		t.Errorf(`%d slow queries below threshold`, len(queries))
+	}
+}
+
+func TestSlowQueryGlobalThreshold(t *testing.T) {
+	var queries []*SlowQuery
+	db, server := openTest(t, `<mapper><update id="update">update users set name = #{name}</update></mapper>`,
+		WithSlowQueryThreshold(10*time.Millisecond, func(q *SlowQuery) { queries = append(queries, q) }))
+	server.setDelay(20 * time.Millisecond)
+
+	db.Mapper(`update`).Args(Args{`name`: `a`}).Execute()
+	db.RawExec(`delete from users`)
+	if len(queries) != 2 || queries[0].MapperID != `update` || queries[1].SQL != `delete from users` {
+		t.Errorf(`SlowQuery = %+v`, queries)
+	}
+}
+
+func TestDebugLogSampling(t *testing.T) {
+	logger := &testLogger{}
+	db, server := openTest(t, slowMapper, WithLogger(logger), WithDebugLogSampling(0))
+
+	db.Mapper(`update`).Args(Args{`name`: `a`}).Execute()
+	if logs := logger.String(); logs != `` {
+		t.Errorf(`debug logs sent: %q`, logs)
+	}
+
+	// the error logs are always sent
+	server.setErr(errors.New(`boom`))
+	db.Mapper(`update`).Args(Args{`name`: `a`}).Execute()
+	if logs := logger.String(); !strings.HasPrefix(logs, `2 update users set name = ?`) {
+		t.Errorf(`error logs = %q`, logs)
+	}
+
+	all := &testLogger{}
+	db, _ = openTest(t, slowMapper, WithLogger(all))
+	for i := 0; i < 3; i++ {
+		db.Mapper(`update`).Args(Args{`name`: `a`}).Execute()
+	}
+	if n := len(all.logs); n != 3 {
+		t.Errorf(`%d debug logs sent without sampling, want 3`, n)
+	}
+}
@@ -1,10 +1,38 @@
 package gobatis
 
+import (
+	"encoding/xml"
+)
+
 type Sql struct {
 	Id   string `xml:"id,attr"`
 	Text string `xml:",chardata"`
+
+	segments []textSegment
 }
 
 func NewSql() *Sql {
 	return &Sql{}
 }
+
+// textSegments return the segments parsed when the xml is parsed, the Sql built in code is parsed now
+func (m *Sql) textSegments() ([]textSegment, error) {
+	if m.segments != nil || m.Text == `` {
+		return m.segments, nil
+	}
+	return parseTextSegments(m.Text)
+}
+
+func (m *Sql) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	type plainSql Sql
+	if err := d.DecodeElement((*plainSql)(m), &start); err != nil {
+		return err
+	}
+
+	segments, err := parseTextSegments(m.Text)
+	if err != nil {
+		return err
+	}
+	m.segments = segments
+	return nil
+}
@@ -0,0 +1,204 @@
+package gobatis
+
+import (
+	"expvar"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// latencyBounds upper bounds of the latency histogram buckets in seconds
+var latencyBounds = []float64{
+	.0001, .00025, .0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10,
+}
+
+// Histogram latency distribution
+type Histogram struct {
+	// Bounds upper bounds of the buckets in seconds, followed by the +Inf bucket
+	Bounds []float64
+	// Counts observations of each bucket, not cumulative, len(Counts) == len(Bounds)+1
+	Counts []int64
+	Count  int64
+	Sum    time.Duration
+}
+
+// StatementStats metrics of the statement, see DB.Stats
+type StatementStats struct {
+	// Calls executions by Find, Execute and RawExec
+	Calls int64
+	// Errors failed executions, ErrorNotFound is not counted in
+	Errors int64
+	// Rows rows returned or affected
+	Rows int64
+	// Render time of rendering the mapper with args
+	Render Histogram
+	// Database time of executing and scanning
+	Database Histogram
+}
+
+type histogram struct {
+	count  int64
+	sum    int64
+	counts []int64
+}
+
+func newHistogram() *histogram {
+	return &histogram{counts: make([]int64, len(latencyBounds)+1)}
+}
+
+func (h *histogram) observe(d time.Duration) {
+	i := sort.SearchFloat64s(latencyBounds, d.Seconds())
+	atomic.AddInt64(&h.counts[i], 1)
+	atomic.AddInt64(&h.sum, int64(d))
+	atomic.AddInt64(&h.count, 1)
+}
+
+func (h *histogram) snapshot() Histogram {
+	s := Histogram{
+		Bounds: latencyBounds,
+		Counts: make([]int64, len(h.counts)),
+		Count:  atomic.LoadInt64(&h.count),
+		Sum:    time.Duration(atomic.LoadInt64(&h.sum)),
+	}
+	for i := range h.counts {
+		s.Counts[i] = atomic.LoadInt64(&h.counts[i])
+	}
+	return s
+}
+
+type statementMetrics struct {
+	calls    int64
+	errors   int64
+	rows     int64
+	render   *histogram
+	database *histogram
+}
+
+// statsRegistry metrics of the statements keyed by mapper id, the raw statements by empty id
+type statsRegistry struct {
+	statements sync.Map
+}
+
+func (r *statsRegistry) of(mapperId string) *statementMetrics {
+	if m, ok := r.statements.Load(mapperId); ok {
+		return m.(*statementMetrics)
+	}
+	m, _ := r.statements.LoadOrStore(mapperId, &statementMetrics{render: newHistogram(), database: newHistogram()})
+	return m.(*statementMetrics)
+}
+
+// observeRender record the render time of the statement started at start
+func (b *DB) observeRender(start time.Time) {
+	if b.stats != nil {
+		b.stats.of(b.mapperId).render.observe(time.Since(start))
+	}
+}
+
+// observe record the execution of the statement started at db.startTime
+func (b *DB) observe(err error, rows int64) {
+	if b.stats == nil {
+		return
+	}
+	m := b.stats.of(b.mapperId)
+	atomic.AddInt64(&m.calls, 1)
+	atomic.AddInt64(&m.rows, rows)
+	if err != nil && err != ErrorNotFound {
+		atomic.AddInt64(&m.errors, 1)
+	}
+	m.database.observe(time.Since(b.startTime))
+}
+
+// Stats return the metrics of the statements keyed by mapper id, the raw statements are keyed by empty id.
+// it's nil if not enabled by WithStats.
+func (b *DB) Stats() map[string]StatementStats {
+	if b.stats == nil {
+		return nil
+	}
+	stats := make(map[string]StatementStats)
+	b.stats.statements.Range(func(key, value any) bool {
+		m := value.(*statementMetrics)
+		stats[key.(string)] = StatementStats{
+			Calls:    atomic.LoadInt64(&m.calls),
+			Errors:   atomic.LoadInt64(&m.errors),
+			Rows:     atomic.LoadInt64(&m.rows),
+			Render:   m.render.snapshot(),
+			Database: m.database.snapshot(),
+		}
+		return true
+	})
+	return stats
+}
+
+// PublishExpvar publish Stats as expvar with name, it panics if name is already registered like expvar.Publish
+func (b *DB) PublishExpvar(name string) {
+	expvar.Publish(name, expvar.Func(func() any {
+		return b.Stats()
+	}))
+}
+
+// StatsHandler serve Stats in the Prometheus text exposition format
+//
+// forexample:
+//
+//	http.Handle(`/metrics/gobatis`, db.StatsHandler())
+func (b *DB) StatsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(`Content-Type`, `text/plain; version=0.0.4; charset=utf-8`)
+		writeStats(w, b.Stats())
+	})
+}
+
+// writeStats write stats in the Prometheus text exposition format, ordered by mapper id
+func writeStats(w io.Writer, stats map[string]StatementStats) {
+	ids := make([]string, 0, len(stats))
+	for id := range stats {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	counter := func(name, help string, value func(s StatementStats) int64) {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n", name, help, name)
+		for _, id := range ids {
+			fmt.Fprintf(w, "%s{mapper=\"%s\"} %d\n", name, escapeLabel(id), value(stats[id]))
+		}
+	}
+	histogram := func(name, help string, value func(s StatementStats) Histogram) {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s histogram\n", name, help, name)
+		for _, id := range ids {
+			h, label := value(stats[id]), escapeLabel(id)
+			var cumulative int64
+			for i, count := range h.Counts {
+				cumulative += count
+				le := `+Inf`
+				if i < len(h.Bounds) {
+					le = strconv.FormatFloat(h.Bounds[i], 'g', -1, 64)
+				}
+				fmt.Fprintf(w, "%s_bucket{mapper=\"%s\",le=\"%s\"} %d\n", name, label, le, cumulative)
+			}
+			fmt.Fprintf(w, "%s_sum{mapper=\"%s\"} %g\n", name, label, h.Sum.Seconds())
+			fmt.Fprintf(w, "%s_count{mapper=\"%s\"} %d\n", name, label, h.Count)
+		}
+	}
+
+	counter(`gobatis_statement_calls_total`, `Executions of the statement.`,
+		func(s StatementStats) int64 { return s.Calls })
+	counter(`gobatis_statement_errors_total`, `Failed executions of the statement.`,
+		func(s StatementStats) int64 { return s.Errors })
+	counter(`gobatis_statement_rows_total`, `Rows returned or affected by the statement.`,
+		func(s StatementStats) int64 { return s.Rows })
+	histogram(`gobatis_statement_render_seconds`, `Time of rendering the statement.`,
+		func(s StatementStats) Histogram { return s.Render })
+	histogram(`gobatis_statement_database_seconds`, `Time of executing the statement and scanning the result.`,
+		func(s StatementStats) Histogram { return s.Database })
+}
+
+// escapeLabel escape the label value of the Prometheus text format
+func escapeLabel(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
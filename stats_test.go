@@ -0,0 +1,91 @@
+package gobatis
+
+import (
+	"database/sql/driver"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStats(t *testing.T) {
+	db, server := openTest(t, interceptorMapper, WithStats())
+	server.setRows([]string{`id`}, []driver.Value{int64(1)}, []driver.Value{int64(2)})
+
+	var users []scanUser
+	for i := 0; i < 2; i++ {
+		if err := db.Mapper(`find`).Args(Args{`id`: 1}).Find(&users).Error; err != nil {
+			t.Fatalf(`Find() error = %v`, err)
+		}
+	}
+	if err := db.Mapper(`update`).Args(Args{`name`: `a`}).Execute().Error; err != nil {
+		t.Fatalf(`Execute() error = %v`, err)
+	}
+	server.setErr(errors.New(`boom`))
+	db.Mapper(`update`).Args(Args{`name`: `a`}).Execute()
+	db.RawExec(`delete from users`)
+
+	stats := db.Stats()
+	find, update, raw := stats[`find`], stats[`update`], stats[``]
+	if find.Calls != 2 || find.Errors != 0 || find.Rows != 4 {
+		t.Errorf(`Stats()[find] = %+v`, find)
+	}
+	if update.Calls != 2 || update.Errors != 1 || update.Rows != 1 {
+		t.Errorf(`Stats()[update] = %+v`, update)
+	}
+	if raw.Calls != 1 || raw.Errors != 1 {
+		t.Errorf(`Stats()[] = %+v`, raw)
+	}
+	if h := find.Database; h.Count != 2 || len(h.Counts) != len(h.Bounds)+1 || h.Sum <= 0 {
+		t.Errorf(`Stats()[find].Database = %+v`, h)
+	}
+	if h := find.Render; h.Count != 2 {
+		t.Errorf(`Stats()[find].Render = %+v`, h)
+	}
+
+	if plain, _ := openTest(t, interceptorMapper); plain.Stats() != nil {
+		t.Error(`Stats() without WithStats is not nil`)
+	}
+}
+
+func TestWriteStats(t *testing.T) {
+	h := Histogram{Bounds: latencyBounds, Counts: make([]int64, len(latencyBounds)+1), Count: 3, Sum: 1500 * time.Millisecond}
+	h.Counts[0], h.Counts[len(latencyBounds)] = 1, 2
+	stats := map[string]StatementStats{
+		`users.find`: {Calls: 3, Errors: 1, Rows: 7, Render: h, Database: h},
+		`a"b`:        {Render: h, Database: h},
+	}
+
+	var out strings.Builder
+	writeStats(&out, stats)
+	for _, line := range []string{
+		"# TYPE gobatis_statement_calls_total counter\ngobatis_statement_calls_total{mapper=\"a\\\"b\"} 0\ngobatis_statement_calls_total{mapper=\"users.find\"} 3\n",
+		`gobatis_statement_errors_total{mapper="users.find"} 1`,
+		`gobatis_statement_rows_total{mapper="users.find"} 7`,
+		`# TYPE gobatis_statement_database_seconds histogram`,
+		`gobatis_statement_database_seconds_bucket{mapper="users.find",le="0.0001"} 1`,
+		`gobatis_statement_database_seconds_bucket{mapper="users.find",le="10"} 1`,
+		`gobatis_statement_database_seconds_bucket{mapper="users.find",le="+Inf"} 3`,
+		`gobatis_statement_database_seconds_sum{mapper="users.find"} 1.5`,
+		`gobatis_statement_render_seconds_count{mapper="users.find"} 3`,
+	} {
+		if !strings.Contains(out.String(), line) {
+			t.Errorf("writeStats() missing %q in\n%s", line, out.String())
+		}
+	}
+}
+
+func TestStatsHandler(t *testing.T) {
+	db, _ := openTest(t, interceptorMapper, WithStats())
+	db.Mapper(`update`).Args(Args{`name`: `a`}).Execute()
+
+	recorder := httptest.NewRecorder()
+	db.StatsHandler().ServeHTTP(recorder, httptest.NewRequest(`GET`, `/metrics`, nil))
+	if ct := recorder.Header().Get(`Content-Type`); !strings.HasPrefix(ct, `text/plain; version=0.0.4`) {
+		t.Errorf(`Content-Type = %q`, ct)
+	}
+	if body := recorder.Body.String(); !strings.Contains(body, `gobatis_statement_calls_total{mapper="update"} 1`) {
+		t.Errorf("body\n%s", body)
+	}
+}
@@ -0,0 +1,207 @@
+package gobatis
+
+import (
+	"container/list"
+	"context"
+	"database/sql"
+	"sync"
+)
+
+// stmtCache LRU cache of prepared statements keyed by the statement sql
+type stmtCache struct {
+	mu      sync.Mutex
+	size    int
+	lru     *list.List
+	entries map[string]*list.Element
+}
+
+type stmtEntry struct {
+	query string
+	stmt  *sql.Stmt
+
+	// refs statement in use, it's closed after released if evicted
+	refs    int
+	evicted bool
+}
+
+func newStmtCache(size int) *stmtCache {
+	return &stmtCache{
+		size:    size,
+		lru:     list.New(),
+		entries: make(map[string]*list.Element, size),
+	}
+}
+
+// acquire return the cached statement of query, prepare it if not cached
+// the least recently used statement is closed when cache is full.
+func (c *stmtCache) acquire(ctx context.Context, db *sql.DB, query string) (*stmtEntry, error) {
+	c.mu.Lock()
+	if el, ok := c.entries[query]; ok {
+		c.lru.MoveToFront(el)
+		entry := el.Value.(*stmtEntry)
+		entry.refs++
+		c.mu.Unlock()
+		return entry, nil
+	}
+	c.mu.Unlock()
+
+	// prepare outside the lock, the slow statements don't block the others
+	stmt, err := db.PrepareContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.entries[query]; ok {
+		// prepared by others at the same time
+		_ = stmt.Close()
+		c.lru.MoveToFront(el)
+		entry := el.Value.(*stmtEntry)
+		entry.refs++
+		return entry, nil
+	}
+
+	entry := &stmtEntry{query: query, stmt: stmt, refs: 1}
+	c.entries[query] = c.lru.PushFront(entry)
+	for c.lru.Len() > c.size {
+		c.remove(c.lru.Back())
+	}
+	return entry, nil
+}
+
+// lookup acquire the cached statement of query, never prepare it
+func (c *stmtCache) lookup(query string) (*stmtEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	el, ok := c.entries[query]
+	if !ok {
+		return nil, false
+	}
+	c.lru.MoveToFront(el)
+	entry := el.Value.(*stmtEntry)
+	entry.refs++
+	return entry, true
+}
+
+// release the statement acquired before
+func (c *stmtCache) release(entry *stmtEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry.refs--
+	if entry.evicted && entry.refs == 0 {
+		_ = entry.stmt.Close()
+	}
+}
+
+// evict remove the statement of query
+func (c *stmtCache) evict(query string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.entries[query]; ok {
+		c.remove(el)
+	}
+}
+
+// purge remove all the statements
+func (c *stmtCache) purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for el := c.lru.Front(); el != nil; el = c.lru.Front() {
+		c.remove(el)
+	}
+}
+
+// remove the element, must be called with lock held
+// the statement is closed until it's not in use.
+func (c *stmtCache) remove(el *list.Element) {
+	entry := c.lru.Remove(el).(*stmtEntry)
+	delete(c.entries, entry.query)
+	entry.evicted = true
+	if entry.refs == 0 {
+		_ = entry.stmt.Close()
+	}
+}
+
+// schemaChanged report whether the prepared statement is invalidated by schema change
+// postgres: 0A000 cached plan must not change result type
+// mysql: 1615 prepared statement needs to be re-prepared
+func schemaChanged(err error) bool {
+	return driverErrorCode(err).match([]string{`0A000`}, []int64{1615}, nil, nil)
+}
+
+// withStmt run fn with the prepared statement of query cached in stmts of pool,
+// the statement invalidated by schema change is evicted, and retried once if not in transaction.
+func (b *DB) withStmt(pool *sql.DB, stmts *stmtCache, query string, fn func(stmt *sql.Stmt) error) error {
+	if b.tx != nil {
+		return b.withTxStmt(stmts, query, fn)
+	}
+
+	for retried := false; ; retried = true {
+		entry, err := stmts.acquire(b.ctx, pool, query)
+		if err != nil {
+			return err
+		}
+
+		err = fn(entry.stmt)
+		stmts.release(entry)
+
+		if err != nil && schemaChanged(err) {
+			stmts.evict(query)
+			if !retried {
+				continue
+			}
+		}
+		return err
+	}
+}
+
+// txStmts the prepared statements bound to the transaction, reused by the statements of the transaction,
+// they're closed with the transaction.
+type txStmts struct {
+	mu    sync.Mutex
+	stmts map[string]*sql.Stmt
+}
+
+// withTxStmt run fn with the statement of query bound to the transaction, the cached statement is reused,
+// otherwise it's prepared on the connection of transaction, as the pool may have no other connection,
+// eg: sqlite with SetMaxOpenConns(1). the statement is kept for the later statements of the transaction.
+func (b *DB) withTxStmt(stmts *stmtCache, query string, fn func(stmt *sql.Stmt) error) error {
+	b.txStmts.mu.Lock()
+	stmt, ok := b.txStmts.stmts[query]
+	if !ok {
+		var err error
+		if entry, cached := stmts.lookup(query); cached {
+			stmt = b.tx.StmtContext(b.ctx, entry.stmt)
+			stmts.release(entry)
+		} else if stmt, err = b.tx.PrepareContext(b.ctx, query); err != nil {
+			b.txStmts.mu.Unlock()
+			return err
+		}
+		b.txStmts.stmts[query] = stmt
+	}
+	b.txStmts.mu.Unlock()
+
+	err := fn(stmt)
+	if err != nil && schemaChanged(err) {
+		stmts.evict(query)
+		b.txStmts.mu.Lock()
+		if b.txStmts.stmts[query] == stmt {
+			delete(b.txStmts.stmts, query)
+		}
+		b.txStmts.mu.Unlock()
+		_ = stmt.Close()
+	}
+	return err
+}
+
+// ResetStmtCache close and remove all the cached prepared statements
+// call it after the schema or the mapper changed, it's noop if statement cache not enabled.
+func (b *DB) ResetStmtCache() {
+	if b.stmts != nil {
+		b.stmts.purge()
+	}
+	if b.replicas != nil {
+		b.replicas.purge()
+	}
+}
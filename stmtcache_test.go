@@ -0,0 +1,198 @@
+package gobatis
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+)
+
+// openTestPool open the fake database of the test without mapper
+func openTestPool(t *testing.T) (*sql.DB, *testServer) {
+	t.Helper()
+	server := &testServer{}
+	testServers.Store(t.Name(), server)
+	pool, err := sql.Open(testDriverName, t.Name())
+	if err != nil {
+		t.Fatalf(`open: %v`, err)
+	}
+	t.Cleanup(func() {
+		_ = pool.Close()
+	})
+	return pool, server
+}
+
+// stmtCount return the prepared and closed statements
+func (s *testServer) stmtCount() (prepared, closed int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.prepared, s.closed
+}
+
+func TestStmtCacheEviction(t *testing.T) {
+	pool, server := openTestPool(t)
+	ctx := context.Background()
+	c := newStmtCache(2)
+
+	for _, query := range []string{`select 1`, `select 2`, `select 1`, `select 3`} {
+		entry, err := c.acquire(ctx, pool, query)
+		if err != nil {
+			t.Fatalf(`acquire(%s) error = %v`, query, err)
+		}
+		c.release(entry)
+	}
+
+	// select 1 is used recently, select 2 is evicted and closed
+	if _, ok := c.entries[`select 2`]; ok || len(c.entries) != 2 {
+		t.Errorf(`cached %d statements, select 2 cached %v`, len(c.entries), ok)
+	}
+	if _, n := server.stmtCount(); n != 1 {
+		t.Errorf(`%d statements closed, want 1`, n)
+	}
+
+	c.purge()
+	if _, n := server.stmtCount(); n != 3 || c.lru.Len() != 0 {
+		t.Errorf(`%d statements closed after purge, want 3`, n)
+	}
+}
+
+func TestStmtCacheInUse(t *testing.T) {
+	pool, server := openTestPool(t)
+	ctx := context.Background()
+	c := newStmtCache(1)
+
+	inUse, err := c.acquire(ctx, pool, `select 1`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := c.acquire(ctx, pool, `select 2`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.release(other)
+
+	// the evicted statement is closed until released
+	if _, n := server.stmtCount(); !inUse.evicted || n != 0 {
+		t.Fatalf(`statement in use closed, evicted %v`, inUse.evicted)
+	}
+	if _, err := inUse.stmt.ExecContext(ctx); err != nil {
+		t.Errorf(`exec the evicted statement in use: %v`, err)
+	}
+	c.release(inUse)
+	if _, n := server.stmtCount(); n != 1 {
+		t.Errorf(`%d statements closed after released, want 1`, n)
+	}
+	c.purge()
+}
+
+func TestStmtCacheShared(t *testing.T) {
+	pool, server := openTestPool(t)
+	ctx := context.Background()
+	c := newStmtCache(4)
+
+	first, err := c.acquire(ctx, pool, `select 1`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := c.acquire(ctx, pool, `select 1`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first != second || first.refs != 2 {
+		t.Errorf(`acquire() not shared, refs %d`, first.refs)
+	}
+	if n, _ := server.stmtCount(); n != 1 {
+		t.Errorf(`%d statements prepared, want 1`, n)
+	}
+	c.release(first)
+	c.release(second)
+	c.purge()
+}
+
+func TestStmtCacheExecute(t *testing.T) {
+	db, server := openTest(t, `<mapper>
+<update id="update">update users set name = #{name}</update>
+</mapper>`, WithStmtCache(4))
+
+	for _, name := range []string{`a`, `b`, `c`} {
+		if err := db.Mapper(`update`).Args(Args{`name`: name}).Execute().Error; err != nil {
+			t.Fatalf(`Execute() error = %v`, err)
+		}
+	}
+	if n := server.count(`update users set name = ?`); n != 3 {
+		t.Errorf(`executed %d times, want 3`, n)
+	}
+	if prepared, _ := server.stmtCount(); prepared != 1 {
+		t.Errorf(`%d statements prepared, want 1`, prepared)
+	}
+
+	if err := db.Close(); err != nil {
+		t.Fatalf(`Close() error = %v`, err)
+	}
+	if _, closed := server.stmtCount(); closed != 1 {
+		t.Errorf(`%d statements closed, want 1`, closed)
+	}
+}
+
+func TestStmtCacheTransaction(t *testing.T) {
+	db, _ := openTest(t, `<mapper></mapper>`, WithStmtCache(4))
+	// the statements of transaction must not wait for another connection of the pool
+	db.db.SetMaxOpenConns(1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	err := db.WithContext(ctx).Transaction(func(tx *DB) error {
+		for i := 0; i < 2; i++ {
+			if err := tx.RawExec(`update users set name = ?`, `a`).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf(`Transaction() error = %v`, err)
+	}
+	if err := db.RawExec(`update users set name = ?`, `b`).Error; err != nil {
+		t.Fatalf(`RawExec() error = %v`, err)
+	}
+}
+
+func TestStmtCacheTransactionReuse(t *testing.T) {
+	db, server := openTest(t, `<mapper></mapper>`, WithStmtCache(4))
+
+	// the statement prepared in transaction is reused by the later ones
+	err := db.Transaction(func(tx *DB) error {
+		for i := 0; i < 3; i++ {
+			if err := tx.RawExec(`update users set name = ?`, `a`).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Savepoint(func(sp *DB) error {
+			return sp.RawExec(`update users set name = ?`, `b`).Error
+		})
+	})
+	if err != nil {
+		t.Fatalf(`Transaction() error = %v`, err)
+	}
+	// the update, SAVEPOINT and RELEASE SAVEPOINT statements
+	if prepared, _ := server.stmtCount(); prepared != 3 {
+		t.Errorf(`%d statements prepared, want 3`, prepared)
+	}
+}
+
+func TestStmtCacheMapperReplaced(t *testing.T) {
+	db, server := openTest(t, `<mapper></mapper>`, WithStmtCache(4))
+	if err := db.RawExec(`update users set name = ?`, `a`).Error; err != nil {
+		t.Fatalf(`RawExec() error = %v`, err)
+	}
+
+	// the statements cached are closed when the mappers are replaced
+	mapper, err := ParseMapperFromBuffer([]byte(`<mapper><update id="update">update users set note = #{note}</update></mapper>`))
+	if err != nil {
+		t.Fatalf(`ParseMapperFromBuffer() error = %v`, err)
+	}
+	WithMapper(mapper)(db)
+	if _, closed := server.stmtCount(); closed != 1 {
+		t.Errorf(`%d statements closed, want 1`, closed)
+	}
+}
@@ -0,0 +1,197 @@
+package gobatis
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strings"
+
+	"github.com/fbatis/expr"
+	"github.com/fbatis/expr/ast"
+	"github.com/fbatis/expr/vm"
+)
+
+// Text chardata of the xml element, split into literal text and the #{} ${} placeholders
+// the expressions of placeholders are compiled when the xml is parsed.
+type Text struct {
+	Text     string
+	segments []textSegment
+}
+
+type textSegment struct {
+	// literal text, or the expression of placeholder
+	text string
+	// 0 for literal text, '#' or '$' for placeholders
+	kind    byte
+	program *vm.Program
+	// root identifiers referenced by the expression
+	names []string
+}
+
+func NewText(data xml.CharData) (*Text, error) {
+	segments, err := parseTextSegments(string(data))
+	if err != nil {
+		return nil, err
+	}
+	return &Text{Text: string(data), segments: segments}, nil
+}
+
+// placeholderStart return the index of the first #{ or ${ in text, -1 if not found
+func placeholderStart(text string) int {
+	for i := 0; i+1 < len(text); i++ {
+		if (text[i] == '#' || text[i] == '$') && text[i+1] == '{' {
+			return i
+		}
+	}
+	return -1
+}
+
+// parseTextSegments split text into literal text and placeholders, and compile the expressions
+func parseTextSegments(text string) ([]textSegment, error) {
+	var segments []textSegment
+	for {
+		start, end := placeholderStart(text), -1
+		if start >= 0 {
+			end = strings.IndexByte(text[start:], '}')
+		}
+		if start < 0 || end < 0 {
+			if text != `` {
+				segments = append(segments, textSegment{text: text})
+			}
+			return segments, nil
+		}
+		end += start
+
+		if start > 0 {
+			segments = append(segments, textSegment{text: text[:start]})
+		}
+		exprString := text[start+2 : end]
+		program, err := expr.Compile(exprString)
+		if err != nil {
+			return nil, fmt.Errorf(`gobatis: compile %s: %w`, text[start:end+1], err)
+		}
+		segments = append(segments, textSegment{
+			text:    exprString,
+			kind:    text[start],
+			program: program,
+			names:   exprNames(program),
+		})
+		text = text[end+1:]
+	}
+}
+
+// exprNames collect the root identifiers of the program, the functions and let variables are excluded
+func exprNames(program *vm.Program) []string {
+	collector := &nameCollector{declared: map[string]bool{}}
+	node := program.Node()
+	ast.Walk(&node, collector)
+
+	var names []string
+	for _, name := range collector.names {
+		if !collector.declared[name] {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// exprFuncs collect the names of the functions called by the program
+func exprFuncs(program *vm.Program) []string {
+	collector := &nameCollector{declared: map[string]bool{}}
+	node := program.Node()
+	ast.Walk(&node, collector)
+	return collector.funcs
+}
+
+type nameCollector struct {
+	names    []string
+	funcs    []string
+	declared map[string]bool
+}
+
+func (c *nameCollector) Visit(node *ast.Node) {
+	switch n := (*node).(type) {
+	case *ast.CallNode:
+		if ident, ok := n.Callee.(*ast.IdentifierNode); ok {
+			c.declared[ident.Value] = true
+			c.funcs = append(c.funcs, ident.Value)
+		}
+	case *ast.VariableDeclaratorNode:
+		c.declared[n.Name] = true
+	case *ast.IdentifierNode:
+		for _, name := range c.names {
+			if name == n.Value {
+				return
+			}
+		}
+		c.names = append(c.names, n.Value)
+	}
+}
+
+// undefinedName return the first name not found in scope
+func undefinedName(names []string, scope map[string]any) (string, bool) {
+	for _, name := range names {
+		if _, ok := scope[name]; !ok {
+			return name, true
+		}
+	}
+	return ``, false
+}
+
+// runExpr run program with scope, the undefined functions are reported by name
+func runExpr(program *vm.Program, scope map[string]any) (any, error) {
+	value, err := expr.Run(program, scope)
+	if err != nil {
+		if name, ok := undefinedName(exprFuncs(program), scope); ok {
+			return nil, fmt.Errorf("gobatis: function not define: %s", name)
+		}
+	}
+	return value, err
+}
+
+// Evaluate write the literal text and placeholders into out,
+// the ${} placeholders are replaced with their values,
+// the #{} placeholders are written as parameter slots.
+func (m *Text) Evaluate(out *Fragments, input *HandlerPayload) error {
+	segments := m.segments
+	if segments == nil && m.Text != `` {
+		// built in code without NewText, parsed on every evaluation
+		var err error
+		if segments, err = parseTextSegments(m.Text); err != nil {
+			return err
+		}
+	}
+	return evaluateSegments(out, segments, input, nil)
+}
+
+// evaluateSegments evaluate the segments, the ${} placeholders found in alias are replaced with the alias value.
+func evaluateSegments(out *Fragments, segments []textSegment, input *HandlerPayload, alias map[string]string) error {
+	for _, segment := range segments {
+		if segment.kind == 0 {
+			out.WriteString(segment.text)
+			continue
+		}
+		if value, ok := alias[segment.text]; ok && segment.kind == '$' {
+			out.WriteString(value)
+			continue
+		}
+
+		value, err := runExpr(segment.program, input.scope)
+		if err != nil {
+			return err
+		}
+		// undefined variables bind NULL with #{}, but never rendered into the statement as text
+		if value != nil || segment.kind == '$' {
+			if name, ok := undefinedName(segment.names, input.scope); ok {
+				return fmt.Errorf("gobatis: Args not define: %s variable", name)
+			}
+		}
+
+		value, secret := unwrapSecret(value)
+		if segment.kind == '$' {
+			out.WriteString(fmt.Sprintf(`%v`, value))
+			continue
+		}
+		out.WriteParam(value, secret || exprSensitive(segment.text, input.sensitive))
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package gobatis
+
+import (
+	"encoding/xml"
+	"testing"
+)
+
+func TestParseTextSegments(t *testing.T) {
+	segments, err := parseTextSegments(`id = #{id} and name in (${names}) and x = '#'`)
+	if err != nil {
+		t.Fatalf(`parseTextSegments() error = %v`, err)
+	}
+	want := []textSegment{
+		{text: `id = `},
+		{text: `id`, kind: '#'},
+		{text: ` and name in (`},
+		{text: `names`, kind: '$'},
+		{text: `) and x = '#'`},
+	}
+	if len(segments) != len(want) {
+		t.Fatalf(`parseTextSegments() = %+v, want %+v`, segments, want)
+	}
+	for i, segment := range segments {
+		if segment.text != want[i].text || segment.kind != want[i].kind || (segment.kind != 0) != (segment.program != nil) {
+			t.Errorf(`segment %d = %+v, want %+v`, i, segment, want[i])
+		}
+	}
+}
+
+func TestCompileErrorsAtParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{`placeholder`, `<mapper><select id="find">select * from users where id = #{id ==}</select></mapper>`},
+		{`if test`, `<mapper><select id="find">select * from users <if test="id >">where id = 1</if></select></mapper>`},
+		{`when test`, `<mapper><select id="find">select * from users <choose><when test="(id">where id = 1</when></choose></select></mapper>`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseMapperFromBuffer([]byte(tt.content)); err == nil {
+				t.Error(`ParseMapperFromBuffer() error = nil, want compile error`)
+			}
+		})
+	}
+}
+
+func TestCompiledMapperReuse(t *testing.T) {
+	db, _ := openTest(t, `<mapper>
+<sql id="columns">id, name</sql>
+<select id="find">select <include refid="columns"/> from users where <if test="id != nil">id = #{id}</if><else>name = #{name}</else></select>
+</mapper>`)
+
+	// the compiled programs are shared by the renders
+	for _, tt := range []struct {
+		variables Args
+		want      string
+	}{
+		{Args{`id`: 1, `name`: nil}, `select id, name from users where id = ?`},
+		{Args{`id`: nil, `name`: `a`}, `select id, name from users where name = ?`},
+		{Args{`id`: 2, `name`: nil}, `select id, name from users where id = ?`},
+	} {
+		statement, _, err := db.Mapper(`find`).Render(tt.variables)
+		if err != nil {
+			t.Fatalf(`Render() error = %v`, err)
+		}
+		if statement != tt.want {
+			t.Errorf("Render(%v)\n got: %q\nwant: %q", tt.variables, statement, tt.want)
+		}
+	}
+}
+
+func TestCodeBuiltMapper(t *testing.T) {
+	// the elements built in code are not compiled by the xml parser
+	where := &If{AttrsMap: map[string]string{TestKey: `id != nil`}, Children: []interface{}{xml.CharData(`where id = #{id}`)}}
+	ids := &Foreach{
+		AttrsMap: map[string]string{CollectionKey: `ids`, ItemKey: `item`, SeparatorKey: `,`},
+		Children: []interface{}{&Text{Text: ` #{item} `}},
+	}
+	mapper := &Mapper{
+		AttrMap: map[string]string{},
+		Select: []*Select{
+			{AttrsMap: map[string]string{IdKey: `find`}, Children: []interface{}{
+				xml.CharData(`select `), &Include{RefId: `columns`}, xml.CharData(` from users `), where,
+			}},
+			{AttrsMap: map[string]string{IdKey: `list`}, Children: []interface{}{
+				xml.CharData(`select * from users where id in (`), ids, xml.CharData(`)`),
+			}},
+		},
+		Sql: []*Sql{{Id: `columns`, Text: `id, ${column}`}},
+	}
+	db, _ := openTest(t, `<mapper></mapper>`, WithMapper(mapper))
+
+	tests := []struct {
+		id        string
+		variables Args
+		want      string
+		args      int
+	}{
+		{`find`, Args{`id`: 1, `column`: `name`}, `select id, name from users where id = ?`, 1},
+		{`find`, Args{`id`: nil, `column`: `note`}, `select id, note from users`, 0},
+		{`list`, Args{`ids`: []int{1, 2}}, `select * from users where id in ( ? , ? )`, 2},
+	}
+	for _, tt := range tests {
+		statement, args, err := db.Mapper(tt.id).Render(tt.variables)
+		if err != nil {
+			t.Fatalf(`Render() error = %v`, err)
+		}
+		if statement != tt.want || len(args) != tt.args {
+			t.Errorf("Render(%v)\n got: %q %v\nwant: %q", tt.variables, statement, args, tt.want)
+		}
+	}
+}
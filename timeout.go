@@ -0,0 +1,57 @@
+package gobatis
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// stmtDeadline the context deadline derived for the running statement
+type stmtDeadline struct {
+	timeout time.Duration
+	parent  context.Context
+	ctx     context.Context
+	cancel  context.CancelFunc
+}
+
+// exceeded report whether the statement is canceled by its own timeout rather than the caller's context
+func (d *stmtDeadline) exceeded() bool {
+	return d != nil && d.ctx.Err() == context.DeadlineExceeded && d.parent.Err() == nil
+}
+
+// statementTimeout timeout attribute of the statement (inherited from mapper), or the default timeout
+func (b *DB) statementTimeout() (time.Duration, error) {
+	value, ok := b.mapperAttr(TimeoutKey)
+	if !ok || value == `` {
+		return b.defaultTimeout, nil
+	}
+	timeout, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf(`gobatis: invalid timeout="%s": %w`, value, err)
+	}
+	return timeout, nil
+}
+
+// withDeadline derive the context of the running statement with its timeout,
+// releaseDeadline must be called after the execution and scanning finished.
+func (db *DB) withDeadline() error {
+	timeout, err := db.statementTimeout()
+	if err != nil || timeout <= 0 {
+		return err
+	}
+	parent := db.ctx
+	ctx, cancel := context.WithTimeout(parent, timeout)
+	db.deadline = &stmtDeadline{timeout: timeout, parent: parent, ctx: ctx, cancel: cancel}
+	db.ctx = ctx
+	return nil
+}
+
+// releaseDeadline cancel the context of the statement and restore the caller's context
+func (db *DB) releaseDeadline() {
+	if db.deadline == nil {
+		return
+	}
+	db.deadline.cancel()
+	db.ctx = db.deadline.parent
+	db.deadline = nil
+}
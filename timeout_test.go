@@ -0,0 +1,81 @@
+package gobatis
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"testing"
+	"time"
+)
+
+const timeoutMapper = `<mapper timeout="50ms">
+<select id="find">select * from users</select>
+<select id="long" timeout="1s">select * from orders</select>
+<update id="update" timeout="50ms">update users set name = #{name}</update>
+</mapper>`
+
+func TestStatementTimeout(t *testing.T) {
+	db, server := openTest(t, timeoutMapper)
+	server.setRows([]string{`id`}, []driver.Value{int64(1)})
+	server.setDelay(200 * time.Millisecond)
+
+	var users []scanUser
+	start := time.Now()
+	err := db.Mapper(`find`).Args(Args{}).Find(&users).Error
+	if !errors.Is(err, ErrorStatementTimeout) || !errors.Is(err, context.DeadlineExceeded) || !IsTimeout(err) {
+		t.Fatalf(`Find() error = %v, want %v`, err, ErrorStatementTimeout)
+	}
+	if elapsed := time.Since(start); elapsed >= 200*time.Millisecond {
+		t.Errorf(`Find() canceled after %v`, elapsed)
+	}
+	var e *Error
+	if !errors.As(err, &e) || e.Timeout != 50*time.Millisecond {
+		t.Errorf(`Error.Timeout = %v, want 50ms`, e)
+	}
+
+	err = db.Mapper(`update`).Args(Args{`name`: `a`}).Execute().Error
+	if !errors.Is(err, ErrorStatementTimeout) {
+		t.Errorf(`Execute() error = %v, want %v`, err, ErrorStatementTimeout)
+	}
+
+	// the statement timeout overrides the mapper's
+	if err := db.Mapper(`long`).Args(Args{}).Find(&users).Error; err != nil {
+		t.Errorf(`Find() error = %v`, err)
+	}
+}
+
+func TestDefaultTimeout(t *testing.T) {
+	db, server := openTest(t, `<mapper><select id="find">select * from users</select></mapper>`, WithDefaultTimeout(50*time.Millisecond))
+	server.setDelay(200 * time.Millisecond)
+
+	var users []scanUser
+	if err := db.Mapper(`find`).Args(Args{}).Find(&users).Error; !errors.Is(err, ErrorStatementTimeout) {
+		t.Errorf(`Find() error = %v, want %v`, err, ErrorStatementTimeout)
+	}
+	if err := db.RawExec(`update users set name = ?`, `a`).Error; !errors.Is(err, ErrorStatementTimeout) {
+		t.Errorf(`RawExec() error = %v, want %v`, err, ErrorStatementTimeout)
+	}
+}
+
+func TestCallerDeadline(t *testing.T) {
+	db, server := openTest(t, timeoutMapper)
+	server.setDelay(200 * time.Millisecond)
+
+	// the caller's context is not the statement timeout
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	var users []scanUser
+	err := db.WithContext(ctx).Mapper(`long`).Args(Args{}).Find(&users).Error
+	if !errors.Is(err, context.DeadlineExceeded) || errors.Is(err, ErrorStatementTimeout) {
+		t.Errorf(`Find() error = %v, want %v only`, err, context.DeadlineExceeded)
+	}
+}
+
+func TestInvalidTimeout(t *testing.T) {
+	db, _ := openTest(t, `<mapper><select id="find" timeout="soon">select * from users</select></mapper>`)
+
+	var users []scanUser
+	if err := db.Mapper(`find`).Args(Args{}).Find(&users).Error; err == nil {
+		t.Error(`Find() with invalid timeout error = nil`)
+	}
+}
@@ -0,0 +1,62 @@
+package gobatis
+
+import (
+	"context"
+	"reflect"
+)
+
+// StatementTransaction type of the spans around Transaction
+const StatementTransaction = `transaction`
+
+// SpanInfo attributes of the span
+type SpanInfo struct {
+	MapperID string
+	// Type statement type, see Invocation.Type, or StatementTransaction
+	Type string
+	// Dialect driver name passed to Open
+	Dialect string
+	// System the well-known name of the database, see Dialect.System
+	System string
+	// SQL the prepared statement, empty for transaction
+	SQL string
+}
+
+// SpanEnd finish the span with the error and the rows returned or affected
+type SpanEnd func(err error, rows int64)
+
+// Tracer start span around each RawQuery, RawExec, Find, Execute and Transaction,
+// the statements are executed with the returned context, so the spans of drivers are nested.
+type Tracer interface {
+	StartSpan(ctx context.Context, info *SpanInfo) (context.Context, SpanEnd)
+}
+
+// startSpan start span of the statement, the context of db is replaced by the span context until the span ended
+func (db *DB) startSpan(typ string) SpanEnd {
+	if db.tracer == nil {
+		return func(error, int64) {}
+	}
+
+	info := &SpanInfo{MapperID: db.mapperId, Type: typ, Dialect: db.driverName, System: db.Dialect().System()}
+	if db.bindVars != nil && typ != StatementTransaction {
+		info.SQL = db.bindVars.stateSql
+	}
+	parent := db.ctx
+	ctx, end := db.tracer.StartSpan(parent, info)
+	db.ctx = ctx
+	return func(err error, rows int64) {
+		end(err, rows)
+		db.ctx = parent
+	}
+}
+
+// resultLen length of the slice dest, -1 if dest is not pointer to slice
+func resultLen(dest any) int {
+	v := reflect.ValueOf(dest)
+	for v.Kind() == reflect.Ptr && !v.IsNil() {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Slice {
+		return -1
+	}
+	return v.Len()
+}
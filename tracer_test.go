@@ -0,0 +1,97 @@
+package gobatis
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testSpanKey context key of the span name
+type testSpanKey struct{}
+
+// testTracer record the spans, the parent of each span is taken from the context
+type testTracer struct {
+	mu    sync.Mutex
+	spans []string
+}
+
+func (tr *testTracer) StartSpan(ctx context.Context, info *SpanInfo) (context.Context, SpanEnd) {
+	parent, _ := ctx.Value(testSpanKey{}).(string)
+	name := info.Type + `:` + info.MapperID
+	return context.WithValue(ctx, testSpanKey{}, name), func(err error, rows int64) {
+		tr.mu.Lock()
+		defer tr.mu.Unlock()
+		tr.spans = append(tr.spans, fmt.Sprintf(`%s<%s> %s %q rows=%d err=%v`, name, parent, info.Dialect, info.SQL, rows, err))
+	}
+}
+
+func TestTracer(t *testing.T) {
+	tracer := &testTracer{}
+	db, server := openTest(t, interceptorMapper, WithTracer(tracer))
+	server.setRows([]string{`id`}, []driver.Value{int64(1)}, []driver.Value{int64(2)})
+
+	err := db.Transaction(func(tx *DB) error {
+		var users []scanUser
+		if err := tx.Mapper(`find`).Args(Args{`id`: 1}).Find(&users).Error; err != nil {
+			return err
+		}
+		return tx.Mapper(`update`).Args(Args{`name`: `a`}).Execute().Error
+	})
+	if err != nil {
+		t.Fatalf(`Transaction() error = %v`, err)
+	}
+
+	cause := errors.New(`boom`)
+	server.setErr(cause)
+	db.RawExec(`delete from users`)
+
+	want := []string{
+		`select:find<transaction:> gobatistest "select * from users where id = ?" rows=2 err=<nil>`,
+		`update:update<transaction:> gobatistest "update users set name = ?" rows=1 err=<nil>`,
+		`transaction:<> gobatistest "" rows=0 err=<nil>`,
+	}
+	if len(tracer.spans) != 4 || !reflect.DeepEqual(tracer.spans[:3], want) {
+		t.Fatalf("spans\n got: %q\nwant: %q", tracer.spans, want)
+	}
+	if span := tracer.spans[3]; span != `raw:<> gobatistest "delete from users" rows=0 err=gobatis: exec: boom` {
+		t.Errorf(`span = %q`, span)
+	}
+}
+
+func TestTracerRows(t *testing.T) {
+	tracer := &testTracer{}
+	db, server := openTest(t, interceptorMapper, WithTracer(tracer), WithDefaultTimeout(time.Second))
+	server.setRows([]string{`id`}, []driver.Value{int64(1)}, []driver.Value{int64(2)})
+	caller := context.WithValue(context.Background(), testSpanKey{}, `caller`)
+
+	// the spans of RawQuery and Bind end after the rows scanned by Find
+	var users []scanUser
+	ndb := db.WithContext(caller).RawQuery(`select * from users`).Find(&users)
+	if ndb.Error != nil {
+		t.Fatalf(`Find() error = %v`, ndb.Error)
+	}
+	// the caller's context is restored after the span ended and the deadline released
+	if ndb.ctx != caller {
+		t.Errorf(`context after Find is not the caller's context`)
+	}
+	users = nil
+	if err := db.WithContext(caller).Mapper(`find`).Bind(Args{`id`: 1}).Find(&users).Error; err != nil {
+		t.Fatalf(`Find() error = %v`, err)
+	}
+	server.setErr(errors.New(`boom`))
+	db.WithContext(caller).RawQuery(`select * from users`)
+
+	want := []string{
+		`raw:<caller> gobatistest "select * from users" rows=2 err=<nil>`,
+		`select:find<caller> gobatistest "select * from users where id = ?" rows=2 err=<nil>`,
+		`raw:<caller> gobatistest "select * from users" rows=0 err=gobatis: exec: boom`,
+	}
+	if !reflect.DeepEqual(tracer.spans, want) {
+		t.Errorf("spans\n got: %q\nwant: %q", tracer.spans, want)
+	}
+}
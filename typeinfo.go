@@ -0,0 +1,122 @@
+package gobatis
+
+import (
+	"reflect"
+	"sync"
+)
+
+// fieldInfo resolved metadata of the struct field
+type fieldInfo struct {
+	name   string
+	column string
+	// index path from the root struct, see reflect.Value.FieldByIndex
+	index []int
+	typ   reflect.Type
+	// tagged with omitempty or `-`
+	optional bool
+	// embedded through pointer, bound but never scanned into
+	viaPointer bool
+}
+
+// typeInfo resolved metadata of the struct type, shared by binding and scanning
+type typeInfo struct {
+	// fields flattened with the embedded struct fields, in declaration order
+	fields []*fieldInfo
+	// names field names and column names of the fields can be scanned into
+	names map[string]*fieldInfo
+	// sensitive names of the fields tagged with gobatis:"sensitive", nested types included
+	sensitive map[string]bool
+}
+
+// typeInfoCache cache of *typeInfo keyed by reflect.Type
+var typeInfoCache sync.Map
+
+// typeInfoOf return the cached metadata of the struct type, resolve it on first use
+func typeInfoOf(typ reflect.Type) *typeInfo {
+	if info, ok := typeInfoCache.Load(typ); ok {
+		return info.(*typeInfo)
+	}
+
+	info := &typeInfo{
+		names:     make(map[string]*fieldInfo, typ.NumField()*2),
+		sensitive: make(map[string]bool),
+	}
+	resolveFields(info, typ, nil, false, map[reflect.Type]bool{typ: true})
+	for _, f := range info.fields {
+		if !f.viaPointer {
+			info.names[f.name] = f
+			info.names[f.column] = f
+		}
+	}
+	collectSensitive(typ, info.sensitive, make(map[reflect.Type]bool))
+
+	actual, _ := typeInfoCache.LoadOrStore(typ, info)
+	return actual.(*typeInfo)
+}
+
+// resolveFields flatten the fields of typ into info, path is the embedded types to avoid recursion
+func resolveFields(info *typeInfo, typ reflect.Type, index []int, viaPointer bool, path map[reflect.Type]bool) {
+	for i := 0; i < typ.NumField(); i++ {
+		f := typ.Field(i)
+		idx := append(append(make([]int, 0, len(index)+1), index...), i)
+
+		if f.Anonymous {
+			embedded, ptr := f.Type, false
+			if embedded.Kind() == reflect.Ptr {
+				embedded, ptr = embedded.Elem(), true
+			}
+			if embedded.Kind() == reflect.Struct {
+				if !path[embedded] {
+					path[embedded] = true
+					resolveFields(info, embedded, idx, viaPointer || ptr, path)
+					delete(path, embedded)
+				}
+				continue
+			}
+		}
+		if f.PkgPath != `` {
+			continue
+		}
+
+		info.fields = append(info.fields, &fieldInfo{
+			name:       f.Name,
+			column:     fieldColumn(f),
+			index:      idx,
+			typ:        f.Type,
+			optional:   fieldOptional(f),
+			viaPointer: viaPointer,
+		})
+	}
+}
+
+// sensitiveOf return the sensitive names of the value type, nil if it's not or contains no struct
+func sensitiveOf(typ reflect.Type) map[string]bool {
+	for {
+		switch typ.Kind() {
+		case reflect.Ptr, reflect.Slice, reflect.Array, reflect.Map:
+			typ = typ.Elem()
+			continue
+		}
+		break
+	}
+	if typ.Kind() != reflect.Struct {
+		return nil
+	}
+	return typeInfoOf(typ).sensitive
+}
+
+// structVariables flatten the struct fields into variables keyed by both field names and column names
+// the fields embedded through nil pointer are skipped.
+func structVariables(value reflect.Value) map[string]any {
+	info := typeInfoOf(value.Type())
+	variables := make(map[string]any, len(info.fields)*2)
+	for _, field := range info.fields {
+		fieldValue, err := value.FieldByIndexErr(field.index)
+		if err != nil {
+			continue
+		}
+		variables[field.column] = fieldValue.Interface()
+		variables[field.name] = fieldValue.Interface()
+	}
+	return variables
+}
@@ -0,0 +1,74 @@
+package gobatis
+
+import (
+	"database/sql/driver"
+	"reflect"
+	"testing"
+)
+
+type infoBase struct {
+	Id      int64 `json:"id"`
+	private int
+}
+
+type infoAudit struct {
+	Creator string `json:"creator"`
+	Parent  *infoNode
+}
+
+type infoNode struct {
+	infoBase
+	*infoAudit
+	Name string `json:"name"`
+	Note string `json:"note,omitempty"`
+}
+
+func TestTypeInfo(t *testing.T) {
+	typ := reflect.TypeOf(infoNode{})
+	info := typeInfoOf(typ)
+	if again := typeInfoOf(typ); again != info {
+		t.Error(`typeInfoOf() resolved the cached type again`)
+	}
+
+	var names []string
+	for _, f := range info.fields {
+		names = append(names, f.name)
+	}
+	if want := []string{`Id`, `Creator`, `Parent`, `Name`, `Note`}; !reflect.DeepEqual(names, want) {
+		t.Errorf(`fields = %v, want %v`, names, want)
+	}
+
+	if f := info.names[`id`]; f == nil || !reflect.DeepEqual(f.index, []int{0, 0}) || f.viaPointer {
+		t.Errorf(`names[id] = %+v`, f)
+	}
+	if f := info.names[`creator`]; f != nil {
+		t.Errorf(`names[creator] = %+v, fields embedded through pointer are not scanned into`, f)
+	}
+	if f := info.names[`note`]; f == nil || !f.optional || info.names[`Note`] != f {
+		t.Errorf(`names[note] = %+v`, f)
+	}
+}
+
+func TestTypeInfoBindAndScan(t *testing.T) {
+	db, server := openTest(t, `<mapper>
+<select id="find">select * from users where id = #{id} and name = #{name} and creator = #{creator}</select>
+</mapper>`)
+
+	node := &infoNode{infoBase: infoBase{Id: 1}, infoAudit: &infoAudit{Creator: `root`}, Name: `a`}
+	_, args, err := db.Mapper(`find`).Render(node)
+	if err != nil {
+		t.Fatalf(`Render() error = %v`, err)
+	}
+	if want := []interface{}{int64(1), `a`, `root`}; !reflect.DeepEqual(args, want) {
+		t.Errorf(`Render() args = %#v, want %#v`, args, want)
+	}
+
+	server.setRows([]string{`id`, `name`, `creator`}, []driver.Value{int64(2), `b`, `admin`})
+	var nodes []infoNode
+	if err := db.Mapper(`find`).Args(node).Find(&nodes).Error; err != nil {
+		t.Fatalf(`Find() error = %v`, err)
+	}
+	if len(nodes) != 1 || nodes[0].Id != 2 || nodes[0].Name != `b` || nodes[0].infoAudit != nil {
+		t.Errorf(`Find() = %+v`, nodes)
+	}
+}
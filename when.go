@@ -3,6 +3,8 @@ package gobatis
 import (
 	"encoding/xml"
 	"strings"
+
+	"github.com/fbatis/expr/vm"
 )
 
 type When struct {
@@ -10,6 +12,9 @@ type When struct {
 	Attrs    []xml.Attr
 	AttrsMap map[string]string
 	Sql      []*Sql
+
+	// program compiled from the test attribute
+	program *vm.Program
 }
 
 func NewWhen() *When {
@@ -25,6 +30,11 @@ func (m *When) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
 	for _, attr := range m.Attrs {
 		m.AttrsMap[XmlName(attr.Name).Name()] = strings.TrimSpace(attr.Value)
 	}
+	if program, err := compileAttr(m.AttrsMap, TestKey); err != nil {
+		return err
+	} else {
+		m.program = program
+	}
 
 	for {
 		tok, err := d.Token()
@@ -39,7 +49,11 @@ func (m *When) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
 				m.Children = append(m.Children, ele)
 			}
 		case xml.CharData:
-			m.Children = append(m.Children, el.Copy())
+			if text, err := NewText(el.Copy()); err != nil {
+				return err
+			} else {
+				m.Children = append(m.Children, text)
+			}
 		case xml.EndElement:
 			return nil
 		case xml.Comment, xml.ProcInst, xml.Directive: